@@ -1,26 +1,99 @@
 package git
 
 import (
+	"bytes"
+	"crypto"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
+	"path"
+	"sort"
 	"strings"
 	"time"
 
 	gogit "github.com/go-git/go-git/v5"
 	gogitconf "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/ProtonMail/go-crypto/openpgp/armor"
-	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
+/*
+Credentials used to authenticate with the git server when cloning, pulling, fetching or pushing.
+Both ssh credentials (see GetSshCredentials) and http credentials (see GetHttpTokenCredentials) satisfy it.
+*/
+type Credentials interface {
+	//Returns the go-git authentication method of the credentials
+	AuthMethod() transport.AuthMethod
+}
+
 /*
 Structure abstracting away ssh.PublicKeys structure needed by go-git to authenticate with git server
 */
 type SshCredentials struct {
-	Keys *ssh.PublicKeys
+	Keys              *ssh.PublicKeys
+	//Optional host key algorithms to negotiate with the git server, in order of preference (ex: []string{"rsa-sha2-512", "ssh-ed25519"}).
+	//If not set, the defaults of the ssh library are used
+	HostKeyAlgorithms []string
+}
+
+type hostKeyAlgorithmsAuth struct {
+	*ssh.PublicKeys
+	algorithms []string
+}
+
+func (a *hostKeyAlgorithmsAuth) ClientConfig() (*gossh.ClientConfig, error) {
+	config, configErr := a.PublicKeys.ClientConfig()
+	if configErr != nil {
+		return nil, configErr
+	}
+
+	config.HostKeyAlgorithms = a.algorithms
+	return config, nil
+}
+
+/*
+Returns the go-git authentication method of the ssh credentials
+*/
+func (cred *SshCredentials) AuthMethod() transport.AuthMethod {
+	if len(cred.HostKeyAlgorithms) == 0 {
+		return cred.Keys
+	}
+
+	return &hostKeyAlgorithmsAuth{cred.Keys, cred.HostKeyAlgorithms}
+}
+
+/*
+Structure abstracting away http.BasicAuth structure needed by go-git to authenticate with git server over https
+*/
+type HttpCredentials struct {
+	Auth *http.BasicAuth
+}
+
+/*
+Returns the go-git authentication method of the http credentials
+*/
+func (cred *HttpCredentials) AuthMethod() transport.AuthMethod {
+	return cred.Auth
+}
+
+/*
+Produces http credentials authenticating with a token (ex: a Github or Gitlab personal access token) to clone/pull a remote repository over https and push to it.
+Github accepts any non-empty username with a token, while Gitlab expects the name of the user owning the token (or "oauth2" for oauth tokens).
+*/
+func GetHttpTokenCredentials(username string, token string) *HttpCredentials {
+	return &HttpCredentials{Auth: &http.BasicAuth{Username: username, Password: token}}
 }
 
 /*
@@ -37,34 +110,132 @@ type GitRepository struct {
 	Repo *gogit.Repository
 }
 
+/*
+Wraps a repository opened or cloned directly with go-git so that it can be passed to the functions of the sdk
+*/
+func NewGitRepository(repo *gogit.Repository) *GitRepository {
+	return &GitRepository{Repo: repo}
+}
+
+var certHostKeyAlgorithms = []string{
+	gossh.CertAlgoED25519v01,
+	gossh.CertAlgoECDSA256v01,
+	gossh.CertAlgoECDSA384v01,
+	gossh.CertAlgoECDSA521v01,
+	gossh.CertAlgoRSASHA512v01,
+	gossh.CertAlgoRSASHA256v01,
+	gossh.KeyAlgoED25519,
+	gossh.KeyAlgoECDSA256,
+	gossh.KeyAlgoECDSA384,
+	gossh.KeyAlgoECDSA521,
+	gossh.KeyAlgoRSASHA512,
+	gossh.KeyAlgoRSASHA256,
+}
+
+func hasCertAuthorities(knownHosts []byte) bool {
+	for _, line := range strings.Split(string(knownHosts), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "@cert-authority") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func withPortHint(callback gossh.HostKeyCallback) gossh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key gossh.PublicKey) error {
+		err := callback(hostname, remote, key)
+
+		var keyErr *knownhosts.KeyError
+		if err != nil && errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			_, port, splitErr := net.SplitHostPort(hostname)
+			if splitErr == nil && port != "22" {
+				return fmt.Errorf("%w (host keys of servers listening on non-standard port %s must be listed as \"[host]:%s\" in the known hosts file)", err, port, port)
+			}
+		}
+
+		return err
+	}
+}
+
 /*
 Produces ssh credentials needed by go-git to clone/pull a remote repository and push to it.
 Arguments are file paths to the private ssh key of the user and ssh host key fingerprint of the git server.
+If the git server listens on a non-standard ssh port (passed in the repository url as in ssh://git@host:2222/org/repo.git),
+its host key must be listed in the known hosts file under the "[host]:port" form, as generated by "ssh-keyscan -p".
+The known hosts file can also contain "@cert-authority" lines, in which case servers presenting a host certificate signed by one of the listed authorities are trusted.
 */
 func GetSshCredentials(sshKeyPath string, knownHostsPath string) (*SshCredentials, error) {
-	_, statErr := os.Stat(sshKeyPath)
-	if statErr != nil {
-		return nil, errors.New(fmt.Sprintf("Failed to access ssh key file %s: %s", sshKeyPath, statErr.Error()))
+	sshKey, readKeyErr := os.ReadFile(sshKeyPath)
+	if readKeyErr != nil {
+		return nil, fmt.Errorf("Failed to access ssh key file %s: %w", sshKeyPath, readKeyErr)
 	}
 
-	publicKeys, pkGenErr := ssh.NewPublicKeysFromFile("git", sshKeyPath, "")
-	if pkGenErr != nil {
-		return nil, errors.New(fmt.Sprintf("Failed to generate public key: %s", pkGenErr.Error()))
+	knownHosts, readErr := os.ReadFile(knownHostsPath)
+	if readErr != nil {
+		return nil, fmt.Errorf("Failed to access known hosts file %s: %w", knownHostsPath, readErr)
 	}
 
-	_, statErr = os.Stat(knownHostsPath)
-	if statErr != nil {
-		return nil, errors.New(fmt.Sprintf("Failed to access known hosts file %s: %s", knownHostsPath, statErr.Error()))
+	return GetSshCredentialsFromBytes(sshKey, knownHosts, "git")
+}
+
+/*
+Same as GetSshCredentials, but takes the content of the private ssh key and of the known hosts file instead of their paths, along with the ssh user (defaults to "git" if empty).
+This allows credentials coming from a secret to be used without ever writing them to the filesystem.
+Hashed hosts, "[host]:port" hosts, wildcard patterns as well as "@cert-authority" and "@revoked" lines are supported in the known hosts.
+*/
+func GetSshCredentialsFromBytes(keyPEM []byte, knownHosts []byte, user string) (*SshCredentials, error) {
+	if user == "" {
+		user = "git"
 	}
-	
-	callback, knowHostsErr := ssh.NewKnownHostsCallback(knownHostsPath)
+
+	publicKeys, pkGenErr := ssh.NewPublicKeys(user, keyPEM, "")
+	if pkGenErr != nil {
+		return nil, fmt.Errorf("Failed to generate public key: %w", pkGenErr)
+	}
+
+	db, knowHostsErr := parseKnownHosts(knownHosts)
 	if knowHostsErr != nil {
-		return nil, errors.New(fmt.Sprintf("Failed to parse known hosts file %s: %s", knownHostsPath, knowHostsErr.Error()))
+		return nil, fmt.Errorf("Failed to parse known hosts: %w", knowHostsErr)
 	}
 
-	(*publicKeys).HostKeyCallbackHelper.HostKeyCallback = callback
+	(*publicKeys).HostKeyCallbackHelper.HostKeyCallback = withPortHint(db.hostKeyCallback())
+
+	//go-git restricts the negotiated host key algorithms to those of the plain host keys listed for the server,
+	//which prevents servers from presenting certificates signed by the authorities of the known hosts file
+	if hasCertAuthorities(knownHosts) {
+		return &SshCredentials{Keys: publicKeys, HostKeyAlgorithms: certHostKeyAlgorithms}, nil
+	}
 
-	return &SshCredentials{publicKeys}, nil
+	return &SshCredentials{Keys: publicKeys}, nil
+}
+
+/*
+Produces ssh credentials that don't verify the host key of the git server, which makes them vulnerable to man-in-the-middle attacks.
+It is only meant for tests against ephemeral git servers whose host key changes on each run. The ssh user defaults to "git" if empty.
+*/
+func GetSshCredentialsInsecure(sshKeyPath string, user string) (*SshCredentials, error) {
+	if user == "" {
+		user = "git"
+	}
+
+	publicKeys, pkGenErr := ssh.NewPublicKeysFromFile(user, sshKeyPath, "")
+	if pkGenErr != nil {
+		return nil, fmt.Errorf("Failed to generate public key: %w", pkGenErr)
+	}
+
+	logger.Printf("Warning: host key verification of the git server is disabled. This should only be done in tests.")
+	(*publicKeys).HostKeyCallbackHelper.HostKeyCallback = gossh.InsecureIgnoreHostKey()
+	return &SshCredentials{Keys: publicKeys}, nil
+}
+
+/*
+Returns a line of a known hosts file trusting the given host key for the server listening at the given host and port.
+For non-standard ports, the host is formatted as "[host]:port", which is the form expected by GetSshCredentials.
+*/
+func KnownHostsEntry(host string, port int, key gossh.PublicKey) string {
+	address := knownhosts.Normalize(net.JoinHostPort(host, fmt.Sprintf("%d", port)))
+	return knownhosts.Line([]string{address}, key)
 }
 
 /*
@@ -74,42 +245,184 @@ Arguments are file paths to an armored private pgp key and optionally a passphra
 func GetSignatureKey(signKeyPath string, passphrasePath string) (*CommitSignatureKey, error) {
 	signKey, readSignKeyErr := os.ReadFile(signKeyPath)
 	if readSignKeyErr != nil {
-		return nil, errors.New(fmt.Sprintf("Error reading signing key: %s", readSignKeyErr.Error()))
+		return nil, fmt.Errorf("Error reading signing key: %w", readSignKeyErr)
 	}
 	
 	signBlock, decErr := armor.Decode(strings.NewReader(string(signKey)))
 	if decErr != nil {
-		return nil, errors.New(fmt.Sprintf("Error decoding signing key: %s", decErr.Error()))
+		return nil, fmt.Errorf("Error decoding signing key: %w", decErr)
 	}
 
 	if signBlock.Type != openpgp.PrivateKeyType {
 		return nil, errors.New("Signing key is not a gpg private key.")
 	}
 
-	signReader := packet.NewReader(signBlock.Body)
-	signEntity, readErr := openpgp.ReadEntity(signReader)
+	//Reading the whole keyring rather than a single entity copes with keys exported along with certification signatures and other entities
+	entities, readErr := openpgp.ReadKeyRing(signBlock.Body)
 	if readErr != nil {
-		return nil, errors.New(fmt.Sprintf("Error parsing signing key: %s", readErr.Error()))
+		return nil, fmt.Errorf("Error parsing signing key: %w", readErr)
 	}
 
-	if signEntity.PrivateKey.Encrypted {
+	var signEntity *openpgp.Entity
+	for _, entity := range entities {
+		if entity.PrivateKey != nil {
+			signEntity = entity
+			break
+		}
+	}
+	if signEntity == nil {
+		return nil, errors.New("Signing key doesn't contain any private key.")
+	}
+
+	if isEntityEncrypted(signEntity) {
 		if passphrasePath == "" {
 			return nil, errors.New("Signing key is encrypted and no passphrase was passed to decrypt it.")
 		}
 
 		passphrase, readPassphraseErr := os.ReadFile(passphrasePath)
 		if readPassphraseErr != nil {
-			return nil, errors.New(fmt.Sprintf("Error reading passphrase: %s", readPassphraseErr.Error()))
+			return nil, fmt.Errorf("Error reading passphrase: %w", readPassphraseErr)
 		}
 
-		decrErr := signEntity.PrivateKey.Decrypt(passphrase)
+		decrErr := decryptEntity(signEntity, passphrase)
 		if decrErr != nil {
-			return nil, errors.New(fmt.Sprintf("Error decrypting signing key with passphrase: %s", decrErr.Error()))
+			return nil, fmt.Errorf("Error decrypting signing key with passphrase: %w", decrErr)
 		}
 	}
 
+	validateErr := validateSigningEntity(signEntity)
+	if validateErr != nil {
+		return nil, validateErr
+	}
+
 	return &CommitSignatureKey{signEntity}, nil
-} 
+}
+
+/*
+Same as GetSignatureKey, but pins the key used to sign to the public key contained in the armored file at the given path (ex: the key selected by user.signingkey).
+The public key must belong to the same identity as the private key and only the subkeys it contains are used to sign.
+Returns an error if the private key doesn't match the public key or if none of the pinned keys can sign.
+*/
+func GetPinnedSignatureKey(signKeyPath string, passphrasePath string, publicKeyPath string) (*CommitSignatureKey, error) {
+	publicKey, readPublicKeyErr := os.ReadFile(publicKeyPath)
+	if readPublicKeyErr != nil {
+		return nil, fmt.Errorf("Error reading public key: %w", readPublicKeyErr)
+	}
+
+	publicEntities, parseErr := openpgp.ReadArmoredKeyRing(bytes.NewReader(publicKey))
+	if parseErr != nil {
+		return nil, fmt.Errorf("Error parsing public key: %w", parseErr)
+	}
+	if len(publicEntities) != 1 {
+		return nil, fmt.Errorf("Public key file should contain exactly one key, but it contains %d", len(publicEntities))
+	}
+	publicEntity := publicEntities[0]
+
+	key, keyErr := GetSignatureKey(signKeyPath, passphrasePath)
+	if keyErr != nil {
+		return nil, keyErr
+	}
+
+	if !bytes.Equal(key.Entity.PrimaryKey.Fingerprint, publicEntity.PrimaryKey.Fingerprint) {
+		return nil, fmt.Errorf("Signing key %X doesn't match public key %X", key.Entity.PrimaryKey.Fingerprint, publicEntity.PrimaryKey.Fingerprint)
+	}
+
+	pinned := map[string]bool{}
+	for _, subkey := range publicEntity.Subkeys {
+		pinned[string(subkey.PublicKey.Fingerprint)] = true
+	}
+
+	subkeys := []openpgp.Subkey{}
+	for _, subkey := range key.Entity.Subkeys {
+		if pinned[string(subkey.PublicKey.Fingerprint)] {
+			subkeys = append(subkeys, subkey)
+		}
+	}
+	key.Entity.Subkeys = subkeys
+
+	validateErr := validateSigningEntity(key.Entity)
+	if validateErr != nil {
+		return nil, validateErr
+	}
+
+	return key, nil
+}
+
+func isEntityEncrypted(entity *openpgp.Entity) bool {
+	if entity.PrivateKey.Encrypted {
+		return true
+	}
+
+	for _, subkey := range entity.Subkeys {
+		if subkey.PrivateKey != nil && subkey.PrivateKey.Encrypted {
+			return true
+		}
+	}
+
+	return false
+}
+
+func decryptEntity(entity *openpgp.Entity, passphrase []byte) error {
+	if entity.PrivateKey.Encrypted {
+		decrErr := entity.PrivateKey.Decrypt(passphrase)
+		if decrErr != nil {
+			return decrErr
+		}
+	}
+
+	//A signing subkey is used in place of the primary key if there is one, so it must be decrypted as well
+	for _, subkey := range entity.Subkeys {
+		if subkey.PrivateKey != nil && subkey.PrivateKey.Encrypted {
+			decrErr := subkey.PrivateKey.Decrypt(passphrase)
+			if decrErr != nil {
+				return decrErr
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateSigningEntity(entity *openpgp.Entity) error {
+	signingKey, ok := entity.SigningKey(time.Now())
+	if !ok {
+		return errors.New("Signing key doesn't have any valid key usable for signing: it might be expired, revoked or missing its self-signatures.")
+	}
+
+	if signingKey.PrivateKey == nil {
+		return errors.New("Signing key doesn't contain the private part of its signing key.")
+	}
+
+	signErr := openpgp.DetachSign(ioutil.Discard, entity, bytes.NewReader([]byte{}), nil)
+	if signErr != nil {
+		return fmt.Errorf("Signing key cannot be used to sign: %w", signErr)
+	}
+
+	return nil
+}
+
+/*
+Returns the public half of the signature key as an armored keyring, suitable to verify the commits it signs.
+*/
+func (key *CommitSignatureKey) ArmoredPublicKey() (string, error) {
+	var buf bytes.Buffer
+	armorWriter, encErr := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if encErr != nil {
+		return "", fmt.Errorf("Error armoring public key: %w", encErr)
+	}
+
+	serErr := key.Entity.Serialize(armorWriter)
+	if serErr != nil {
+		return "", fmt.Errorf("Error serializing public key: %w", serErr)
+	}
+
+	closeErr := armorWriter.Close()
+	if closeErr != nil {
+		return "", fmt.Errorf("Error armoring public key: %w", closeErr)
+	}
+
+	return buf.String(), nil
+}
 
 /*
 Verifies that the top commit of a given git repository was signed by one of the keys that are passed in the argument. 
@@ -118,89 +431,515 @@ Returns an error if it isn't.
 func VerifyTopCommit(repo *GitRepository, armoredKeyrings []string) error {
 	head, headErr := repo.Repo.Head()
 	if headErr != nil {
-		return errors.New(fmt.Sprintf("Error accessing repo head: %s", headErr.Error()))
+		return fmt.Errorf("Error accessing repo head: %w", headErr)
 	}
 
 	commit, commitErr := repo.Repo.CommitObject(head.Hash())
 	if commitErr != nil {
-		return errors.New(fmt.Sprintf("Error accessing repo top commit: %s", commitErr.Error()))
+		return fmt.Errorf("Error accessing repo top commit: %w", commitErr)
+	}
+
+	if commit.PGPSignature == "" {
+		return fmt.Errorf("Error verifying top commit \"%s\": %w", head.Hash(), ErrUnsignedCommit)
 	}
 
 	for _, armoredKeyring := range armoredKeyrings {
 		entity, err := commit.Verify(armoredKeyring)
 		if err == nil {
 			for _, identity := range entity.Identities {
-				fmt.Println(fmt.Sprintf("Validated top commit \"%s\" is signed by user \"%s\"", head.Hash(), (*identity).Name))
+				logger.Printf("Validated top commit \"%s\" is signed by user \"%s\"", head.Hash(), (*identity).Name)
 			}
 			return nil
 		}
 	}
 
-	return errors.New(fmt.Sprintf("Top commit \"%s\" isn't signed with any of the trusted keys", head.Hash()))
+	return fmt.Errorf("Top commit \"%s\" isn't signed with any of the trusted keys", head.Hash())
 }
 
 /*
 Optional parameters to pass to the CommitFiles command
 */
 type CommitOptions struct {
-	//Name of the commiter
-	Name           string
-	//Email of the commiter
-	Email          string
+	//Name of the author of the commit. Unless the committer name is set, it is used for the committer as well
+	Name              string
+	//Email of the author of the commit. Unless the committer email is set, it is used for the committer as well
+	Email             string
+	//Optional name of the committer if it differs from the author. If only the committer name is set, it is used for the author as well
+	CommitterName     string
+	//Optional email of the committer if it differs from the author. If only the committer email is set, it is used for the author as well
+	CommitterEmail    string
 	//Optional key used to signed the git commit
-	SignatureKey   *CommitSignatureKey
+	SignatureKey      *CommitSignatureKey
+	//Optional digest algorithm used to sign the git commit (ex: crypto.SHA512). If not set, the default of the openpgp library is used
+	SignatureHash     crypto.Hash
+	//Glob patterns of paths that should never be committed by CommitAllChanges, even if they changed
+	Exclude           []string
+	//If true, an ErrNothingToCommit error is returned instead of silently not committing when there are no changes
+	RequireChanges    bool
+	//If true, ReauthorTopCommit keeps the original author and committer dates of the commit instead of setting them to the current time
+	PreserveDates     bool
+	//If true, commits are allowed on a detached HEAD, where they don't belong to any branch, instead of returning an ErrDetachedHead error
+	AllowDetachedHead bool
+	//Optional normalization applied to the worktree content of the committed text files before they are staged
+	Normalization     ContentNormalization
+}
+
+/*
+Error returned by the commit functions when there are no changes to commit and the RequireChanges option is set
+*/
+var ErrNothingToCommit = errors.New("There are no changes to commit.")
+
+var defaultAuthorName string
+var defaultAuthorEmail string
+
+/*
+Sets the identity used as the author of commits when it is neither passed in the commit options nor configured in git (user.name and user.email).
+*/
+func SetDefaultAuthor(name string, email string) {
+	defaultAuthorName = name
+	defaultAuthorEmail = email
+}
+
+func resolveAuthor(repo *GitRepository, opts CommitOptions) (*object.Signature, error) {
+	name := opts.Name
+	email := opts.Email
+
+	if name == "" || email == "" {
+		conf, confErr := repo.Repo.ConfigScoped(gogitconf.SystemScope)
+		if confErr != nil {
+			return nil, fmt.Errorf("Error accessing repo configuration: %w", confErr)
+		}
+
+		if name == "" {
+			name = conf.User.Name
+		}
+		if email == "" {
+			email = conf.User.Email
+		}
+	}
+
+	if name == "" {
+		name = defaultAuthorName
+	}
+	if email == "" {
+		email = defaultAuthorEmail
+	}
+
+	if name == "" || email == "" {
+		return nil, errors.New("Could not resolve the name and email of the commit author: pass them in the commit options, configure user.name and user.email in git or set a default with SetDefaultAuthor.")
+	}
+
+	return &object.Signature{
+		Name: name,
+		Email: email,
+		When: time.Now(),
+	}, nil
+}
+
+/*
+Error returned by the commit functions when some of the files to commit could not be staged, usually because they are ignored by a .gitignore file.
+It lists the files that were not staged. Nothing is committed when it is returned.
+*/
+type FilesNotStagedError struct {
+	Files []string
+}
+
+func (e *FilesNotStagedError) Error() string {
+	return fmt.Sprintf("The following files could not be staged, they might be ignored: %s", strings.Join(e.Files, ", "))
+}
+
+//go-git stages ignored files when they are explicitly added, so they are detected before staging like git does
+func getIgnoredFiles(w *gogit.Worktree, idx *index.Index, files []string) ([]string, error) {
+	patterns, patternsErr := gitignore.ReadPatterns(w.Filesystem, nil)
+	if patternsErr != nil {
+		return nil, fmt.Errorf("Error reading ignore rules of the worktree: %w", patternsErr)
+	}
+	patterns = append(patterns, w.Excludes...)
+	matcher := gitignore.NewMatcher(patterns)
+
+	ignored := []string{}
+	for _, file := range files {
+		//Ignore rules don't apply to files that are already tracked
+		_, entryErr := idx.Entry(file)
+		if entryErr == nil {
+			continue
+		}
+
+		info, statErr := w.Filesystem.Lstat(file)
+		if statErr != nil {
+			continue
+		}
+
+		if matcher.Match(strings.Split(path.Clean(file), "/"), info.IsDir()) {
+			ignored = append(ignored, file)
+		}
+	}
+
+	return ignored, nil
+}
+
+func getUnstagedFiles(repo *GitRepository, w *gogit.Worktree, files []string) ([]string, error) {
+	idx, idxErr := repo.Repo.Storer.Index()
+	if idxErr != nil {
+		return nil, fmt.Errorf("Error accessing repo index: %w", idxErr)
+	}
+
+	unstaged := []string{}
+	for _, file := range files {
+		info, statErr := w.Filesystem.Lstat(file)
+		if statErr != nil || info.IsDir() {
+			continue
+		}
+
+		_, entryErr := idx.Entry(file)
+		if entryErr == index.ErrEntryNotFound {
+			unstaged = append(unstaged, file)
+		} else if entryErr != nil {
+			return nil, fmt.Errorf("Error accessing file %s in repo index: %w", file, entryErr)
+		}
+	}
+
+	return unstaged, nil
+}
+
+func resolveAuthorAndCommitter(repo *GitRepository, opts CommitOptions) (*object.Signature, *object.Signature, error) {
+	authorOpts := opts
+	if authorOpts.Name == "" {
+		authorOpts.Name = opts.CommitterName
+	}
+	if authorOpts.Email == "" {
+		authorOpts.Email = opts.CommitterEmail
+	}
+
+	author, authorErr := resolveAuthor(repo, authorOpts)
+	if authorErr != nil {
+		return nil, nil, authorErr
+	}
+
+	committer := *author
+	if opts.CommitterName != "" {
+		committer.Name = opts.CommitterName
+	}
+	if opts.CommitterEmail != "" {
+		committer.Email = opts.CommitterEmail
+	}
+
+	return author, &committer, nil
+}
+
+func isStagedIdentically(w *gogit.Worktree, idx *index.Index, file string) bool {
+	info, statErr := w.Filesystem.Lstat(file)
+	if statErr != nil || !info.Mode().IsRegular() {
+		return false
+	}
+
+	entry, entryErr := idx.Entry(file)
+	if entryErr != nil {
+		return false
+	}
+
+	mode, modeErr := filemode.NewFromOSFileMode(info.Mode())
+	if modeErr != nil || mode != entry.Mode || uint32(info.Size()) != entry.Size {
+		return false
+	}
+
+	content, readErr := readWorktreeFile(w, file)
+	if readErr != nil {
+		return false
+	}
+
+	return plumbing.ComputeHash(plumbing.BlobObject, content) == entry.Hash
+}
+
+func stageRemoval(w *gogit.Worktree, idx *index.Index, file string) error {
+	//The index can be shared with the worktree, so the entries to remove are listed before any of them is removed
+	removed := []string{}
+	for _, entry := range idx.Entries {
+		if entry.Name == file || strings.HasPrefix(entry.Name, file + "/") {
+			removed = append(removed, entry.Name)
+		}
+	}
+
+	if len(removed) == 0 {
+		return fmt.Errorf("Error staging removal of file %s for commit: it is neither in the worktree nor in the index", file)
+	}
+
+	for _, name := range removed {
+		_, removeErr := w.Remove(name)
+		if removeErr != nil {
+			return fmt.Errorf("Error staging removal of file %s for commit: %w", name, removeErr)
+		}
+	}
+
+	return nil
+}
+
+//Git sorts tree entries by name, with a trailing slash for sub-trees
+func treeEntrySortKey(entry object.TreeEntry) string {
+	if entry.Mode == filemode.Dir {
+		return entry.Name + "/"
+	}
+
+	return entry.Name
+}
+
+//Stores the trees of the given index entries, whose names all start with the given prefix, and returns the hash of the root one
+func storeIndexTree(repo *GitRepository, entries []*index.Entry, prefix string) (plumbing.Hash, error) {
+	tree := &object.Tree{Entries: []object.TreeEntry{}}
+	dirs := []string{}
+	dirEntries := map[string][]*index.Entry{}
+	for _, entry := range entries {
+		name := strings.TrimPrefix(entry.Name, prefix)
+		if slashIdx := strings.Index(name, "/"); slashIdx != -1 {
+			dir := name[:slashIdx]
+			if _, ok := dirEntries[dir]; !ok {
+				dirs = append(dirs, dir)
+			}
+			dirEntries[dir] = append(dirEntries[dir], entry)
+			continue
+		}
+
+		tree.Entries = append(tree.Entries, object.TreeEntry{Name: name, Mode: entry.Mode, Hash: entry.Hash})
+	}
+
+	for _, dir := range dirs {
+		dirHash, dirErr := storeIndexTree(repo, dirEntries[dir], prefix+dir+"/")
+		if dirErr != nil {
+			return plumbing.ZeroHash, dirErr
+		}
+
+		tree.Entries = append(tree.Entries, object.TreeEntry{Name: dir, Mode: filemode.Dir, Hash: dirHash})
+	}
+
+	sort.Slice(tree.Entries, func(i, j int) bool {
+		return treeEntrySortKey(tree.Entries[i]) < treeEntrySortKey(tree.Entries[j])
+	})
+
+	return storeTree(repo, tree)
+}
+
+//Commits the staged changes with a signature made with the given digest algorithm, like the commit function of go-git does with its default one
+func commitIndexWithSignatureHash(repo *GitRepository, msg string, author *object.Signature, committer *object.Signature, key *CommitSignatureKey, hash crypto.Hash) (plumbing.Hash, error) {
+	idx, idxErr := repo.Repo.Storer.Index()
+	if idxErr != nil {
+		return plumbing.ZeroHash, fmt.Errorf("Error accessing repo index: %w", idxErr)
+	}
+
+	treeHash, treeErr := storeIndexTree(repo, idx.Entries, "")
+	if treeErr != nil {
+		return plumbing.ZeroHash, treeErr
+	}
+
+	parents := []plumbing.Hash{}
+	head, headErr := repo.Repo.Head()
+	if headErr == nil {
+		parents = append(parents, head.Hash())
+	} else if headErr != plumbing.ErrReferenceNotFound {
+		return plumbing.ZeroHash, fmt.Errorf("Error accessing repo head: %w", headErr)
+	}
+
+	commit := &object.Commit{
+		Author:       *author,
+		Committer:    *committer,
+		Message:      msg,
+		TreeHash:     treeHash,
+		ParentHashes: parents,
+	}
+
+	signErr := signCommit(commit, key, hash)
+	if signErr != nil {
+		return plumbing.ZeroHash, signErr
+	}
+
+	commitHash, storeErr := storeCommit(repo, commit)
+	if storeErr != nil {
+		return plumbing.ZeroHash, storeErr
+	}
+
+	moveErr := moveHead(repo, commitHash)
+	if moveErr != nil {
+		return plumbing.ZeroHash, moveErr
+	}
+
+	return commitHash, nil
 }
 
 /*
 Commits the given list of files in the git repository.
 If not changes are detected in the files provided, a commit will not be attempted.
+If HEAD is detached, an ErrDetachedHead error is returned unless the AllowDetachedHead option is set.
+If some of the files could not be staged (ex: because they are ignored), a *FilesNotStagedError listing them is returned and nothing is committed.
+Files that no longer exist in the worktree are staged as removals, so additions, modifications and deletions can be committed together,
+whether the repository is on the filesystem or in memory.
 */
 func CommitFiles(repo *GitRepository, files []string, msg string, opts CommitOptions) (bool, error) {
+	if !opts.AllowDetachedHead {
+		detached, detachedErr := IsDetachedHead(repo)
+		if detachedErr != nil {
+			return false, detachedErr
+		}
+
+		if detached {
+			return false, ErrDetachedHead
+		}
+	}
+
 	w, wErr := repo.Repo.Worktree()
 	if wErr != nil {
-		return false, errors.New(fmt.Sprintf("Error accessing repo worktree: %s", wErr.Error()))
+		return false, fmt.Errorf("Error accessing repo worktree: %w", wErr)
+	}
+
+	idx, idxErr := repo.Repo.Storer.Index()
+	if idxErr != nil {
+		return false, fmt.Errorf("Error accessing repo index: %w", idxErr)
+	}
+
+	ignored, ignoredErr := getIgnoredFiles(w, idx, files)
+	if ignoredErr != nil {
+		return false, ignoredErr
+	}
+
+	if len(ignored) > 0 {
+		return false, &FilesNotStagedError{Files: ignored}
 	}
 
 	for _, file := range files {
+		_, statErr := w.Filesystem.Lstat(file)
+		if statErr != nil && os.IsNotExist(statErr) {
+			removeErr := stageRemoval(w, idx, file)
+			if removeErr != nil {
+				return false, removeErr
+			}
+			continue
+		}
+
+		normalizeErr := normalizeWorktreeFile(w, file, opts.Normalization)
+		if normalizeErr != nil {
+			return false, normalizeErr
+		}
+
+		//Staging a file computes the status of the whole worktree, so it is skipped for files that are already staged as they are
+		if isStagedIdentically(w, idx, file) {
+			continue
+		}
+
 		_, addErr := w.Add(file)
 		if addErr != nil {
-			return false, errors.New(fmt.Sprintf("Error staging file %s for commit: %s", file, addErr.Error()))
+			return false, fmt.Errorf("Error staging file %s for commit: %w", file, addErr)
 		}
 	}
 
+	unstaged, unstagedErr := getUnstagedFiles(repo, w, files)
+	if unstagedErr != nil {
+		return false, unstagedErr
+	}
+
+	if len(unstaged) > 0 {
+		return false, &FilesNotStagedError{Files: unstaged}
+	}
+
 	stat, statErr := w.Status()
 	if statErr != nil {
-		return false, errors.New(fmt.Sprintf("Error getting repo status after staging files: %s", statErr.Error()))
+		return false, fmt.Errorf("Error getting repo status after staging files: %w", statErr)
 	}
 
 	if len(stat) == 0 {
-		fmt.Println("Will not commit as there are no changes to commit.")
+		if opts.RequireChanges {
+			return false, ErrNothingToCommit
+		}
+
+		logger.Printf("Will not commit as there are no changes to commit.")
 		return false, nil
 	}
 
-	comOpts := gogit.CommitOptions{}
-	if opts.Name != "" || opts.Email != "" {
-		comOpts.Author = &object.Signature{
-			Name: opts.Name,
-			Email: opts.Email,
-			When: time.Now(),
-		}
+	author, committer, authorErr := resolveAuthorAndCommitter(repo, opts)
+	if authorErr != nil {
+		return false, authorErr
 	}
 
-	if opts.SignatureKey != nil {
-		comOpts.SignKey = opts.SignatureKey.Entity
-	}
+	var commErr error
+	//go-git doesn't allow the digest algorithm of the signature to be configured, so the commit is built and signed separately when one is requested
+	if opts.SignatureKey != nil && opts.SignatureHash != 0 {
+		_, commErr = commitIndexWithSignatureHash(repo, msg, author, committer, opts.SignatureKey, opts.SignatureHash)
+	} else {
+		comOpts := gogit.CommitOptions{
+			Author: author,
+			Committer: committer,
+		}
+
+		if opts.SignatureKey != nil {
+			comOpts.SignKey = opts.SignatureKey.Entity
+		}
 
-	_, commErr := w.Commit(msg, &comOpts)
+		_, commErr = w.Commit(msg, &comOpts)
+	}
 	if commErr != nil {
-		return false, errors.New(fmt.Sprintf("Error commiting file changes: %s", commErr.Error()))
+		return false, fmt.Errorf("Error commiting file changes: %w", commErr)
 	}
 
-	fmt.Printf("Committed following changes with message \"%s\": \n%s\n", msg, stat.String())
+	logger.Printf("Committed following changes with message \"%s\": \n%s", msg, stat.String())
+	repo.recordOperation(CommitOperation, fmt.Sprintf("Committed %d files with message \"%s\"", len(stat), msg))
 
 	return true, nil
 }
 
+/*
+Accumulates files to commit across several calls so that they can be committed together in a single commit.
+*/
+type CommitBatch struct {
+	repo  *GitRepository
+	files []string
+}
+
+/*
+Returns an empty commit batch for the given git repository.
+*/
+func NewCommitBatch(repo *GitRepository) *CommitBatch {
+	return &CommitBatch{repo: repo, files: []string{}}
+}
+
+/*
+Adds the given files to the list of files that will be committed. Files that were already staged in the batch are ignored.
+*/
+func (b *CommitBatch) Stage(files ...string) {
+	for _, file := range files {
+		alreadyStaged := false
+		for _, stagedFile := range b.files {
+			if stagedFile == file {
+				alreadyStaged = true
+				break
+			}
+		}
+
+		if !alreadyStaged {
+			b.files = append(b.files, file)
+		}
+	}
+}
+
+/*
+Commits all the files accumulated in the batch in a single commit, with the same behavior as the CommitFiles function.
+If the commit succeeds, the batch is emptied and can be reused.
+*/
+func (b *CommitBatch) Commit(msg string, opts CommitOptions) (bool, error) {
+	if len(b.files) == 0 {
+		if opts.RequireChanges {
+			return false, ErrNothingToCommit
+		}
+
+		logger.Printf("Will not commit as there are no files staged in the batch.")
+		return false, nil
+	}
+
+	committed, err := CommitFiles(b.repo, b.files, msg, opts)
+	if err != nil {
+		return committed, err
+	}
+
+	b.files = []string{}
+	return committed, nil
+}
+
 /*
 Function signature meant to be passed as an argument to the PushChanges function.
 It should return a git repository with changes to push if there are changes to push otherwise it should return nil.
@@ -208,50 +947,395 @@ The function should be idempotent as it might be called repeatedly if there is a
 */
 type PushPreHook func() (*GitRepository, error)
 
+/*
+Optional parameters to pass to the PushChangesWithOptions command
+*/
+type PushOptions struct {
+	//Number of times to retry the push if it fails due to remote updates. A negative value will retry indefinitely
+	Retries            int64
+	//Interval to wait before each retry
+	RetryInterval      time.Duration
+	//Optional callback invoked before each retry with the attempt number (starting at 1) and the error that caused the retry
+	OnRetry            func(attempt int, err error)
+	//Optional hash the reference is expected to be at on origin. If set, the push is forced, but only if the reference on origin
+	//is still at that hash (like "git push --force-with-lease"). Otherwise, a *LeaseRejectedError is returned and the push is not retried.
+	//It must be a full commit hash, or an ErrInvalidLease error is returned before the hook is invoked
+	Lease              string
+	//If true, pushes are allowed while HEAD is detached instead of returning an ErrDetachedHead error.
+	//Note that commits made on a detached HEAD are not part of the pushed branch
+	AllowDetachedHead  bool
+	//Optional tag to create on the pushed commit and push to origin once the commit was pushed.
+	//It is skipped, with the PushStatusTagSkipped status, if the hook indicates there is nothing to push
+	Tag                *TagOptions
+	//Optional callback returning fresh credentials (ex: a new short-lived http token) invoked before each retry and before pushing the tag.
+	//It allows long retry loops to outlive the credentials initially passed to the push
+	RefreshCredentials func() (Credentials, error)
+}
+
+/*
+Error returned by the push functions when a push with a lease was rejected because the reference on origin moved from the expected hash.
+*/
+type LeaseRejectedError struct {
+	Ref      string
+	Expected string
+	//Error returned by go-git when it rejected the push
+	Err      error
+}
+
+func (e *LeaseRejectedError) Error() string {
+	return fmt.Sprintf("Push with lease on reference \"%s\" was rejected as it is no longer at the expected commit \"%s\": %s", e.Ref, e.Expected, e.Err.Error())
+}
+
+func (e *LeaseRejectedError) Unwrap() error {
+	return e.Err
+}
+
+/*
+Error returned, wrapped, by the push functions when the Lease option is not a full commit hash
+*/
+var ErrInvalidLease = errors.New("The lease of a push must be a full commit hash.")
+
+/*
+Error returned, wrapped, by the push functions when the push kept being rejected because origin was updated with commits that are not in the local history
+*/
+var ErrNonFastForward = errors.New("The remote reference has commits that are not in the local history.")
+
 /*
 Takes a function argument that should return a git repository with changes to push if there are (and nil otherwise).
 From there, it will try to push the new commits in the repository to the given reference on origin.
+If the reference doesn't exist on origin yet (ex: a branch created with CreateBranch), it is created by the push.
 If there are conflicts during the push, it will keep retrying by re-invoking its function argument and push on the returned repository.
+The returned repository can be in memory: the function argument can re-clone it with MemCloneGitRepo on each invocation,
+in which case it should clear the memory store of the previous invocation to free it.
+*/
+func PushChanges(hook PushPreHook, ref string, creds Credentials, retries int64, retryInterval time.Duration) error {
+	return PushChangesWithOptions(hook, ref, creds, PushOptions{
+		Retries: retries,
+		RetryInterval: retryInterval,
+	})
+}
+
+/*
+Same as PushChanges, but takes its retry parameters and other optional parameters as an options argument.
 */
-func PushChanges(hook PushPreHook, ref string, sshCred *SshCredentials, retries int64, retryInterval time.Duration) error {
+func PushChangesWithOptions(hook PushPreHook, ref string, creds Credentials, opts PushOptions) error {
+	_, err := PushChangesWithResult(hook, ref, creds, opts)
+	return err
+}
+
+/*
+Status of a push made with PushChangesWithResult that did not fail
+*/
+type PushStatus string
+
+const (
+	//New commits were pushed to origin
+	PushStatusPushed        PushStatus = "pushed"
+	//Origin already had all the commits, so nothing was sent to it
+	PushStatusUpToDate      PushStatus = "up-to-date"
+	//The hook indicated there was nothing to push
+	PushStatusNothingToPush PushStatus = "nothing-to-push"
+	//The hook indicated there was nothing to push while the Tag option was set. As the hook returned no repository, no commit was tagged
+	PushStatusTagSkipped    PushStatus = "tag-skipped"
+)
+
+/*
+Outcome of a push made with PushChangesWithResult
+*/
+type PushResult struct {
+	Status PushStatus
+	//Hash of the top commit of the pushed reference. It is empty if the hook indicated there was nothing to push
+	Commit string
+	//Name of the tag created on the pushed commit if the Tag option was set
+	Tag    string
+}
+
+/*
+Same as PushChangesWithOptions, but also returns whether commits were actually pushed, as opposed to origin already being up to date, along with the pushed commit.
+If the Tag option is set, the pushed commit is tagged once the push succeeded and the tag is pushed to origin as well.
+If the hook indicates there is nothing to push, there is no repository to tag either, so the tag is not created and the PushStatusTagSkipped status is returned instead of PushStatusNothingToPush.
+*/
+func PushChangesWithResult(hook PushPreHook, ref string, creds Credentials, opts PushOptions) (*PushResult, error) {
+	repo, status, pushErr := pushChanges(hook, ref, creds, opts, 1)
+	if pushErr != nil {
+		return nil, pushErr
+	}
+
+	if repo == nil {
+		if opts.Tag != nil {
+			logger.Printf("Will not create tag \"%s\" as there is nothing to push.", opts.Tag.Name)
+			return &PushResult{Status: PushStatusTagSkipped}, nil
+		}
+
+		return &PushResult{Status: status}, nil
+	}
+
+	head, headErr := repo.Repo.Head()
+	if headErr != nil {
+		return nil, fmt.Errorf("Error accessing repo head: %w", headErr)
+	}
+
+	result := &PushResult{Status: status, Commit: head.Hash().String()}
+	if opts.Tag != nil {
+		if opts.RefreshCredentials != nil {
+			refreshed, refreshErr := opts.RefreshCredentials()
+			if refreshErr != nil {
+				return result, fmt.Errorf("Error refreshing credentials: %w", refreshErr)
+			}
+			creds = refreshed
+		}
+
+		tagErr := tagAndPush(repo, head.Hash(), opts.Tag, creds)
+		if tagErr != nil {
+			return result, tagErr
+		}
+
+		result.Tag = opts.Tag.Name
+	}
+
+	return result, nil
+}
+
+func pushChanges(hook PushPreHook, ref string, creds Credentials, opts PushOptions, attempt int) (*GitRepository, PushStatus, error) {
+	if opts.Lease != "" && !plumbing.IsHash(opts.Lease) {
+		return nil, "", fmt.Errorf("Invalid lease \"%s\" for reference \"%s\": %w", opts.Lease, ref, ErrInvalidLease)
+	}
+
 	repo, hookErr := hook()
 	if hookErr != nil {
-		return hookErr
+		return nil, "", hookErr
 	}
 
 	//Repo object is nil, indicating there is nothing to push
 	if repo == nil {
-		return nil
+		return nil, PushStatusNothingToPush, nil
+	}
+
+	if !opts.AllowDetachedHead {
+		detached, detachedErr := IsDetachedHead(repo)
+		if detachedErr != nil {
+			return nil, "", detachedErr
+		}
+
+		if detached {
+			return nil, "", ErrDetachedHead
+		}
 	}
 
 	refMap := gogitconf.RefSpec(fmt.Sprintf("refs/heads/%s:refs/heads/%s", ref, ref))
-	pushErr := repo.Repo.Push(&gogit.PushOptions{
-		Auth: sshCred.Keys,
+	pushOpts := gogit.PushOptions{
+		Auth: creds.AuthMethod(),
 		Force: false,
 		Prune: false,
 		RemoteName: "origin",
 		RefSpecs: []gogitconf.RefSpec{refMap},
-	})
+	}
+	if opts.Lease != "" {
+		pushOpts.Force = true
+		pushOpts.RequireRemoteRefs = []gogitconf.RefSpec{
+			gogitconf.RefSpec(fmt.Sprintf("%s:refs/heads/%s", opts.Lease, ref)),
+		}
+	}
+	pushErr := repo.Repo.Push(&pushOpts)
 
 	if pushErr != nil {
+		//go-git doesn't return a typed error when the reference required by the lease moved, so the prefix of its message for the pushed reference is matched
+		leasePrefix := fmt.Sprintf("remote ref %s required to be ", plumbing.NewBranchReferenceName(ref))
+		if opts.Lease != "" && strings.HasPrefix(pushErr.Error(), leasePrefix) {
+			return nil, "", &LeaseRejectedError{Ref: ref, Expected: opts.Lease, Err: pushErr}
+		}
+
 		if pushErr.Error() == gogit.NoErrAlreadyUpToDate.Error() {
-			fmt.Println("Push operation was no-op as remote was already up to date.")
-			return nil
+			logger.Printf("Push operation was no-op as remote was already up to date.")
+			return repo, PushStatusUpToDate, nil
 		}
 
+		//Pushing a branch that doesn't exist on origin yet cannot be a non-fast-forward update unless another party created it concurrently,
+		//in which case re-invoking the hook to integrate its commits is the expected behavior
 		if strings.HasPrefix(pushErr.Error(), "non-fast-forward update:") {
-			if retries == 0 {
-				return errors.New(fmt.Sprintf("Push operation continuously failed due to remote updates. Giving up."))
+			if opts.Retries == 0 {
+				return nil, "", fmt.Errorf("Push operation continuously failed due to remote updates. Giving up: %w", ErrNonFastForward)
 			}
 			
-			fmt.Println("Push operation failed as remote was updated with non-local commits. Will retry.")
-			time.Sleep(retryInterval)
+			logger.Printf("Push operation failed as remote was updated with non-local commits. Will retry.")
+			if opts.OnRetry != nil {
+				opts.OnRetry(attempt, pushErr)
+			}
+			time.Sleep(opts.RetryInterval)
+
+			if opts.RefreshCredentials != nil {
+				refreshed, refreshErr := opts.RefreshCredentials()
+				if refreshErr != nil {
+					return nil, "", fmt.Errorf("Error refreshing credentials: %w", refreshErr)
+				}
+				creds = refreshed
+			}
+
+			opts.Retries = opts.Retries - 1
+			return pushChanges(hook, ref, creds, opts, attempt + 1)
+		}
+
+		return nil, "", fmt.Errorf("Error pushing file changes: %w", pushErr)
+	}
+
+	repo.recordOperation(PushOperation, fmt.Sprintf("Pushed branch \"%s\" to origin", ref))
+	return repo, PushStatusPushed, nil
+}
+
+/*
+Signs, verifies and pushes a commit in a single operation.
+The hook argument should return a git repository where the given files have been changed (but not committed) or nil if there is nothing to push.
+The files are then committed with the signature key of the commit options, which is mandatory, and the commit is verified locally against the public half of the key.
+The commit is pushed with the same behavior as PushChangesWithOptions only if the verification passes, which catches signing misconfigurations before they reach the remote.
+*/
+func SignCommitVerifyPush(hook PushPreHook, files []string, msg string, opts CommitOptions, ref string, creds Credentials, pushOpts PushOptions) error {
+	if opts.SignatureKey == nil {
+		return errors.New("A signature key is required to sign the commit.")
+	}
+
+	armoredPublicKey, keyErr := opts.SignatureKey.ArmoredPublicKey()
+	if keyErr != nil {
+		return keyErr
+	}
+
+	verifier, verifierErr := NewVerifier([]string{armoredPublicKey})
+	if verifierErr != nil {
+		return verifierErr
+	}
+
+	commitHook := func() (*GitRepository, error) {
+		repo, hookErr := hook()
+		if hookErr != nil || repo == nil {
+			return nil, hookErr
+		}
+
+		committed, commitErr := CommitFiles(repo, files, msg, opts)
+		if commitErr != nil {
+			return nil, commitErr
+		}
+
+		if !committed {
+			return nil, nil
+		}
 
-			return PushChanges(hook, ref, sshCred, retries - 1, retryInterval)
+		verifyErr := verifier.VerifyTopCommit(repo)
+		if verifyErr != nil {
+			return nil, fmt.Errorf("Signed commit failed local verification and will not be pushed: %w", verifyErr)
 		}
 
-		return errors.New(fmt.Sprintf("Error pushing file changes: %s", pushErr.Error()))
+		return repo, nil
+	}
+
+	return PushChangesWithOptions(commitHook, ref, creds, pushOpts)
+}
+
+func isExcluded(file string, patterns []string) bool {
+	for _, pattern := range patterns {
+		for candidate := file; candidate != "." && candidate != "/"; candidate = path.Dir(candidate) {
+			matched, _ := path.Match(pattern, candidate)
+			if matched {
+				return true
+			}
+
+			matched, _ = path.Match(pattern, path.Base(candidate))
+			if matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func unstageFile(repo *GitRepository, file string) error {
+	idx, idxErr := repo.Repo.Storer.Index()
+	if idxErr != nil {
+		return fmt.Errorf("Error accessing repo index: %w", idxErr)
+	}
+
+	var headFile *object.File
+	head, headErr := repo.Repo.Head()
+	if headErr == nil {
+		commit, commitErr := repo.Repo.CommitObject(head.Hash())
+		if commitErr != nil {
+			return fmt.Errorf("Error accessing repo top commit: %w", commitErr)
+		}
+
+		headFile, _ = commit.File(file)
+	}
+
+	if headFile == nil {
+		_, removeErr := idx.Remove(file)
+		if removeErr != nil && removeErr != index.ErrEntryNotFound {
+			return fmt.Errorf("Error unstaging file %s: %w", file, removeErr)
+		}
+	} else {
+		entry, entryErr := idx.Entry(file)
+		if entryErr != nil {
+			entry = idx.Add(file)
+		}
+
+		//The whole entry is restored from the top commit so that no stat information of the unstaged content (ex: its size or modification time) is left behind
+		*entry = index.Entry{
+			Name: file,
+			Hash: headFile.Hash,
+			Mode: headFile.Mode,
+			Size: uint32(headFile.Size),
+		}
+	}
+
+	setErr := repo.Repo.Storer.SetIndex(idx)
+	if setErr != nil {
+		return fmt.Errorf("Error updating repo index: %w", setErr)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+/*
+Commits all the changed files in the worktree of the git repository, including new untracked files and deleted files.
+Files matching one of the glob patterns in the Exclude option will be left out of the commit, even if they were already staged.
+If no changes are detected, a commit will not be attempted.
+*/
+func CommitAllChanges(repo *GitRepository, msg string, opts CommitOptions) (bool, error) {
+	w, wErr := repo.Repo.Worktree()
+	if wErr != nil {
+		return false, fmt.Errorf("Error accessing repo worktree: %w", wErr)
+	}
+
+	stat, statErr := w.Status()
+	if statErr != nil {
+		return false, fmt.Errorf("Error getting repo status: %w", statErr)
+	}
+
+	files := []string{}
+	for file, fileStat := range stat {
+		if fileStat.Worktree == gogit.Unmodified && fileStat.Staging == gogit.Unmodified {
+			continue
+		}
+
+		if isExcluded(file, opts.Exclude) {
+			if fileStat.Staging != gogit.Unmodified && fileStat.Staging != gogit.Untracked {
+				unstageErr := unstageFile(repo, file)
+				if unstageErr != nil {
+					return false, unstageErr
+				}
+			}
+			continue
+		}
+
+		files = append(files, file)
+	}
+
+	if len(files) == 0 {
+		if opts.RequireChanges {
+			return false, ErrNothingToCommit
+		}
+
+		logger.Printf("Will not commit as there are no changes to commit.")
+		return false, nil
+	}
+
+	sort.Strings(files)
+	return CommitFiles(repo, files, msg, opts)
+}
@@ -0,0 +1,683 @@
+package git
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func getTestRemoteBranchFiles(t *testing.T, remote string, branch string) map[string]string {
+	t.Helper()
+
+	remoteRepo, openErr := gogit.PlainOpen(remote)
+	if openErr != nil {
+		t.Fatal(openErr)
+	}
+
+	ref, refErr := remoteRepo.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if refErr != nil {
+		t.Fatal(refErr)
+	}
+
+	commit, commitErr := remoteRepo.CommitObject(ref.Hash())
+	if commitErr != nil {
+		t.Fatal(commitErr)
+	}
+
+	return getTestTreeFiles(t, commit)
+}
+
+func commitInMemory(t *testing.T, remote string, filePath string, content string) *GitRepository {
+	t.Helper()
+
+	repo, store, cloneErr := MemCloneGitRepo(remote, "main", 0, noCredentials{})
+	if cloneErr != nil {
+		t.Fatal(cloneErr)
+	}
+
+	if err := store.SetFileContent(filePath, content); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CommitFiles(repo, []string{filePath}, "Set "+filePath, testCommitOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	return repo
+}
+
+func TestPushChangesInMemoryRetriesOnNonFastForward(t *testing.T) {
+	remote := newTestRemote(t, map[string]string{"a.txt": "a\n"})
+
+	invocations := 0
+	hook := func() (*GitRepository, error) {
+		invocations++
+		repo := commitInMemory(t, remote, "ours.txt", "ours\n")
+
+		//A concurrent push lands on origin after the first clone, so the first push is not a fast-forward
+		if invocations == 1 {
+			concurrent := commitInMemory(t, remote, "theirs.txt", "theirs\n")
+			if err := PushChanges(pushHook(concurrent), "main", noCredentials{}, 0, 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return repo, nil
+	}
+
+	retries := []int{}
+	result, pushErr := PushChangesWithResult(hook, "main", noCredentials{}, PushOptions{
+		Retries: 1,
+		OnRetry: func(attempt int, err error) {
+			retries = append(retries, attempt)
+		},
+	})
+	if pushErr != nil {
+		t.Fatal(pushErr)
+	}
+
+	if invocations != 2 || len(retries) != 1 || retries[0] != 1 {
+		t.Fatalf("Expected the hook to be invoked twice with a single retry, got %d invocations and retries %v", invocations, retries)
+	}
+	if result.Status != PushStatusPushed {
+		t.Fatalf("Expected status %s, got %s", PushStatusPushed, result.Status)
+	}
+
+	assertTestFiles(t, getTestRemoteBranchFiles(t, remote, "main"), map[string]string{
+		"a.txt":      "a\n",
+		"ours.txt":   "ours\n",
+		"theirs.txt": "theirs\n",
+	})
+}
+
+func TestPushChangesInMemoryGivesUpOnNonFastForward(t *testing.T) {
+	remote := newTestRemote(t, map[string]string{"a.txt": "a\n"})
+
+	stale := commitInMemory(t, remote, "ours.txt", "ours\n")
+	concurrent := commitInMemory(t, remote, "theirs.txt", "theirs\n")
+	if err := PushChanges(pushHook(concurrent), "main", noCredentials{}, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	pushErr := PushChanges(pushHook(stale), "main", noCredentials{}, 0, 0)
+	if !errors.Is(pushErr, ErrNonFastForward) {
+		t.Fatalf("Expected an ErrNonFastForward error, got %v", pushErr)
+	}
+}
+
+func newTestRepo(t testing.TB, files map[string]string) (*GitRepository, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	repo, initErr := InitRepo(dir, "main")
+	if initErr != nil {
+		t.Fatal(initErr)
+	}
+
+	paths := writeTestFiles(t, dir, files)
+	if _, err := CommitFiles(repo, paths, "Initial commit", testCommitOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	return repo, dir
+}
+
+func TestCommitFilesRejectsIgnoredFiles(t *testing.T) {
+	repo, dir := newTestRepo(t, map[string]string{"tracked/debug.log": "tracked before the ignore rules\n"})
+
+	ignoreFiles := writeTestFiles(t, dir, map[string]string{
+		".gitignore":     "*.log\nbuild/\n",
+		"sub/.gitignore": "local.txt\n",
+	})
+	if _, err := CommitFiles(repo, ignoreFiles, "Add ignore rules", testCommitOptions); err != nil {
+		t.Fatal(err)
+	}
+	initial := getTestHeadCommit(t, repo)
+
+	writeTestFiles(t, dir, map[string]string{
+		"a.txt":             "a\n",
+		"b.log":             "b\n",
+		"build/out.txt":     "out\n",
+		"sub/local.txt":     "local\n",
+		"tracked/debug.log": "changed\n",
+	})
+
+	_, commitErr := CommitFiles(repo, []string{"a.txt", "b.log", "build/out.txt", "sub/local.txt", "tracked/debug.log"}, "Commit ignored files", testCommitOptions)
+	var notStagedErr *FilesNotStagedError
+	if !errors.As(commitErr, &notStagedErr) {
+		t.Fatalf("Expected a FilesNotStagedError, got %v", commitErr)
+	}
+
+	expected := []string{"b.log", "build/out.txt", "sub/local.txt"}
+	if strings.Join(notStagedErr.Files, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected ignored files %v, got %v", expected, notStagedErr.Files)
+	}
+
+	if head := getTestHeadCommit(t, repo); head.Hash != initial.Hash {
+		t.Fatalf("Expected nothing to be committed, but the top commit moved to %s", head.Hash)
+	}
+
+	committed, commitErr := CommitFiles(repo, []string{"a.txt", "tracked/debug.log"}, "Commit tracked files", testCommitOptions)
+	if commitErr != nil || !committed {
+		t.Fatalf("Expected files that aren't ignored to be committed, got %t and error %v", committed, commitErr)
+	}
+}
+
+func TestCommitFilesAddsAndDeletes(t *testing.T) {
+	repo, dir := newTestRepo(t, map[string]string{
+		"a.txt":         "a\n",
+		"removed.txt":   "removed\n",
+		"old/one.txt":   "one\n",
+		"old/two/2.txt": "two\n",
+		"keep.txt":      "keep\n",
+	})
+
+	writeTestFiles(t, dir, map[string]string{"a.txt": "changed\n", "new/b.txt": "b\n"})
+	if err := os.Remove(filepath.Join(dir, "removed.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "old")); err != nil {
+		t.Fatal(err)
+	}
+
+	committed, commitErr := CommitFiles(repo, []string{"a.txt", "new/b.txt", "removed.txt", "old"}, "Add and delete files", testCommitOptions)
+	if commitErr != nil {
+		t.Fatal(commitErr)
+	}
+	if !committed {
+		t.Fatal("Expected changes to be committed")
+	}
+
+	assertTestFiles(t, getTestTreeFiles(t, getTestHeadCommit(t, repo)), map[string]string{
+		"a.txt":     "changed\n",
+		"new/b.txt": "b\n",
+		"keep.txt":  "keep\n",
+	})
+
+	_, missingErr := CommitFiles(repo, []string{"missing.txt"}, "Delete missing file", testCommitOptions)
+	if missingErr == nil {
+		t.Fatal("Expected an error when deleting a file that is neither in the worktree nor in the index")
+	}
+}
+
+func TestResolveAuthorAndCommitter(t *testing.T) {
+	repo, _ := newTestRepo(t, map[string]string{"a.txt": "a\n"})
+	conf, confErr := repo.Repo.Config()
+	if confErr != nil {
+		t.Fatal(confErr)
+	}
+	conf.User.Name = "Config User"
+	conf.User.Email = "config@example.com"
+	if err := repo.Repo.SetConfig(conf); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name           string
+		opts           CommitOptions
+		authorName     string
+		authorEmail    string
+		committerName  string
+		committerEmail string
+	}{
+		{
+			name:           "neither set",
+			opts:           CommitOptions{},
+			authorName:     "Config User",
+			authorEmail:    "config@example.com",
+			committerName:  "Config User",
+			committerEmail: "config@example.com",
+		},
+		{
+			name:           "author only",
+			opts:           CommitOptions{Name: "Author", Email: "author@example.com"},
+			authorName:     "Author",
+			authorEmail:    "author@example.com",
+			committerName:  "Author",
+			committerEmail: "author@example.com",
+		},
+		{
+			name:           "committer only",
+			opts:           CommitOptions{CommitterName: "Committer", CommitterEmail: "committer@example.com"},
+			authorName:     "Committer",
+			authorEmail:    "committer@example.com",
+			committerName:  "Committer",
+			committerEmail: "committer@example.com",
+		},
+		{
+			name:           "both set",
+			opts:           CommitOptions{Name: "Author", Email: "author@example.com", CommitterName: "Committer", CommitterEmail: "committer@example.com"},
+			authorName:     "Author",
+			authorEmail:    "author@example.com",
+			committerName:  "Committer",
+			committerEmail: "committer@example.com",
+		},
+	}
+
+	for _, c := range cases {
+		author, committer, err := resolveAuthorAndCommitter(repo, c.opts)
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+
+		if author.Name != c.authorName || author.Email != c.authorEmail {
+			t.Errorf("%s: expected author %s <%s>, got %s <%s>", c.name, c.authorName, c.authorEmail, author.Name, author.Email)
+		}
+		if committer.Name != c.committerName || committer.Email != c.committerEmail {
+			t.Errorf("%s: expected committer %s <%s>, got %s <%s>", c.name, c.committerName, c.committerEmail, committer.Name, committer.Email)
+		}
+		if !committer.When.Equal(author.When) {
+			t.Errorf("%s: expected author and committer to share the same date", c.name)
+		}
+	}
+}
+
+//Writes an armored private key for the given identity whose identity is certified by another key, exported after the public key of the certifier like "gpg --export-secret-keys" of a keyring does
+func writeTestCertifiedSigningKey(t *testing.T, dir string, name string, email string) string {
+	t.Helper()
+
+	signer, signerErr := openpgp.NewEntity(name, "", email, nil)
+	if signerErr != nil {
+		t.Fatal(signerErr)
+	}
+	certifier, certifierErr := openpgp.NewEntity("Certifier", "", "certifier@example.com", nil)
+	if certifierErr != nil {
+		t.Fatal(certifierErr)
+	}
+	for identity := range signer.Identities {
+		if err := signer.SignIdentity(identity, certifier, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	armorWriter, armorErr := armor.Encode(&buf, openpgp.PrivateKeyType, nil)
+	if armorErr != nil {
+		t.Fatal(armorErr)
+	}
+	if err := certifier.Serialize(armorWriter); err != nil {
+		t.Fatal(err)
+	}
+	if err := signer.SerializePrivate(armorWriter, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := armorWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	keyPath := filepath.Join(dir, "signing-key.asc")
+	if err := os.WriteFile(keyPath, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return keyPath
+}
+
+func TestSignAndVerifyWithCertifiedKey(t *testing.T) {
+	keyPath := writeTestCertifiedSigningKey(t, t.TempDir(), "Test Author", "author@example.com")
+
+	key, keyErr := GetSignatureKey(keyPath, "")
+	if keyErr != nil {
+		t.Fatal(keyErr)
+	}
+	for _, identity := range key.Entity.Identities {
+		if len(identity.Signatures) < 2 {
+			t.Fatalf("expected identity %s of the fixture key to carry a certification besides its self-signature", identity.Name)
+		}
+	}
+
+	repo, dir := newTestRepo(t, map[string]string{"a.txt": "a\n"})
+	paths := writeTestFiles(t, dir, map[string]string{"b.txt": "b\n"})
+	opts := testCommitOptions
+	opts.SignatureKey = key
+	if _, err := CommitFiles(repo, paths, "Signed commit", opts); err != nil {
+		t.Fatal(err)
+	}
+
+	armoredPublicKey, armorErr := key.ArmoredPublicKey()
+	if armorErr != nil {
+		t.Fatal(armorErr)
+	}
+	if err := VerifyTopCommit(repo, []string{armoredPublicKey}); err != nil {
+		t.Fatalf("expected commit signed with the certified key to be verified, got: %s", err)
+	}
+
+	otherKey, otherErr := GetSignatureKey(writeTestCertifiedSigningKey(t, t.TempDir(), "Other Author", "other@example.com"), "")
+	if otherErr != nil {
+		t.Fatal(otherErr)
+	}
+	otherPublicKey, otherArmorErr := otherKey.ArmoredPublicKey()
+	if otherArmorErr != nil {
+		t.Fatal(otherArmorErr)
+	}
+	if err := VerifyTopCommit(repo, []string{otherPublicKey}); err == nil {
+		t.Fatal("expected commit to be rejected by a keyring without its signing key")
+	}
+}
+
+func TestValidateSigningEntityRejectsRevokedKey(t *testing.T) {
+	entity, entityErr := openpgp.NewEntity("Test Author", "", "author@example.com", nil)
+	if entityErr != nil {
+		t.Fatal(entityErr)
+	}
+	if err := validateSigningEntity(entity); err != nil {
+		t.Fatalf("expected fresh key to be valid, got: %s", err)
+	}
+
+	if err := entity.RevokeKey(packet.KeyCompromised, "compromised", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateSigningEntity(entity); err == nil {
+		t.Fatal("expected revoked key to be rejected")
+	}
+}
+
+func newBenchmarkRepo(b *testing.B, count int) (*GitRepository, []string) {
+	b.Helper()
+
+	files := map[string]string{}
+	for i := 0; i < count; i++ {
+		files[fmt.Sprintf("dir%d/file%d.txt", i%10, i)] = strings.Repeat(fmt.Sprintf("line %d\n", i), 20)
+	}
+	repo, _ := newTestRepo(b, files)
+
+	paths := []string{}
+	for filePath := range files {
+		paths = append(paths, filePath)
+	}
+
+	return repo, paths
+}
+
+func BenchmarkIsStagedIdentically(b *testing.B) {
+	repo, paths := newBenchmarkRepo(b, 200)
+	w, wErr := repo.Repo.Worktree()
+	if wErr != nil {
+		b.Fatal(wErr)
+	}
+	idx, idxErr := repo.Repo.Storer.Index()
+	if idxErr != nil {
+		b.Fatal(idxErr)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, filePath := range paths {
+			if !isStagedIdentically(w, idx, filePath) {
+				b.Fatalf("expected file %s to be staged identically", filePath)
+			}
+		}
+	}
+}
+
+//Baseline for BenchmarkIsStagedIdentically: staging the same unchanged files again, as CommitFiles did before skipping them
+func BenchmarkAddUnchangedFiles(b *testing.B) {
+	repo, paths := newBenchmarkRepo(b, 200)
+	w, wErr := repo.Repo.Worktree()
+	if wErr != nil {
+		b.Fatal(wErr)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, filePath := range paths {
+			if _, err := w.Add(filePath); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestOperationLogOfPositionalLiteral(t *testing.T) {
+	dir := t.TempDir()
+	repo, initErr := gogit.PlainInit(dir, false)
+	if initErr != nil {
+		t.Fatal(initErr)
+	}
+
+	//Repositories opened directly with go-git are still wrapped with positional literals by existing callers
+	wrapped := &GitRepository{repo}
+	log := NewOperationLog()
+	wrapped.AttachOperationLog(log)
+
+	paths := writeTestFiles(t, dir, map[string]string{"a.txt": "a\n"})
+	if _, err := CommitFiles(wrapped, paths, "Initial commit", testCommitOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	history := wrapped.History()
+	if len(history) != 1 || history[0].Kind != CommitOperation || history[0].Commit != getTestHeadCommit(t, wrapped).Hash.String() {
+		t.Fatalf("Expected the commit to be recorded, got %+v", history)
+	}
+
+	wrapped.AttachOperationLog(nil)
+	if history := wrapped.History(); history != nil {
+		t.Fatalf("Expected no history once the log is detached, got %+v", history)
+	}
+	if operations := log.Operations(); len(operations) != 1 {
+		t.Fatalf("Expected the detached log to keep its operations, got %+v", operations)
+	}
+}
+
+func TestCommitAllChangesUnstagesExcludedFiles(t *testing.T) {
+	repo, dir := newTestRepo(t, map[string]string{"a.txt": "a\n", "secret.env": "secret\n"})
+	writeTestFiles(t, dir, map[string]string{"a.txt": "changed\n", "secret.env": "a much longer secret\n", "new.txt": "new\n"})
+
+	w, wErr := repo.Repo.Worktree()
+	if wErr != nil {
+		t.Fatal(wErr)
+	}
+	if _, err := w.Add("secret.env"); err != nil {
+		t.Fatal(err)
+	}
+
+	committed, commitErr := CommitAllChanges(repo, "Commit all but secrets", CommitOptions{Name: "Test Author", Email: "author@example.com", Exclude: []string{"*.env"}})
+	if commitErr != nil {
+		t.Fatal(commitErr)
+	}
+	if !committed {
+		t.Fatal("Expected changes to be committed")
+	}
+
+	head := getTestHeadCommit(t, repo)
+	assertTestFiles(t, getTestTreeFiles(t, head), map[string]string{
+		"a.txt":      "changed\n",
+		"new.txt":    "new\n",
+		"secret.env": "secret\n",
+	})
+
+	idx, idxErr := repo.Repo.Storer.Index()
+	if idxErr != nil {
+		t.Fatal(idxErr)
+	}
+	entry, entryErr := idx.Entry("secret.env")
+	if entryErr != nil {
+		t.Fatal(entryErr)
+	}
+	headFile, headFileErr := head.File("secret.env")
+	if headFileErr != nil {
+		t.Fatal(headFileErr)
+	}
+	if entry.Hash != headFile.Hash || entry.Mode != headFile.Mode || int64(entry.Size) != headFile.Size || !entry.ModifiedAt.IsZero() {
+		t.Fatalf("Expected the index entry of the excluded file to be restored from the top commit, got %+v", entry)
+	}
+
+	stat, statErr := w.Status()
+	if statErr != nil {
+		t.Fatal(statErr)
+	}
+	if fileStat := stat.File("secret.env"); fileStat.Staging != gogit.Unmodified || fileStat.Worktree != gogit.Modified {
+		t.Fatalf("Expected the excluded file to only be modified in the worktree, got staging %q and worktree %q", fileStat.Staging, fileStat.Worktree)
+	}
+}
+
+func TestCommitFilesCommitsPreviouslyStagedFiles(t *testing.T) {
+	repo, dir := newTestRepo(t, map[string]string{"a.txt": "a\n"})
+	paths := writeTestFiles(t, dir, map[string]string{"a.txt": "changed\n"})
+
+	w, wErr := repo.Repo.Worktree()
+	if wErr != nil {
+		t.Fatal(wErr)
+	}
+	if _, err := w.Add("a.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	committed, commitErr := CommitFiles(repo, paths, "Commit staged file", testCommitOptions)
+	if commitErr != nil {
+		t.Fatal(commitErr)
+	}
+	if !committed {
+		t.Fatal("Expected the previously staged file to be committed")
+	}
+	assertTestFiles(t, getTestTreeFiles(t, getTestHeadCommit(t, repo)), map[string]string{"a.txt": "changed\n"})
+
+	committed, commitErr = CommitFiles(repo, paths, "No changes", testCommitOptions)
+	if commitErr != nil || committed {
+		t.Fatalf("Expected no commit without changes, got %t and error %v", committed, commitErr)
+	}
+}
+
+func TestPushChangesWithResultSkipsTagWithNothingToPush(t *testing.T) {
+	nothingToPush := func() (*GitRepository, error) {
+		return nil, nil
+	}
+
+	result, pushErr := PushChangesWithResult(nothingToPush, "main", noCredentials{}, PushOptions{})
+	if pushErr != nil || result.Status != PushStatusNothingToPush {
+		t.Fatalf("Expected nothing to push, got %+v (%v)", result, pushErr)
+	}
+
+	result, pushErr = PushChangesWithResult(nothingToPush, "main", noCredentials{}, PushOptions{Tag: &TagOptions{Name: "v1.0.0"}})
+	if pushErr != nil || result.Status != PushStatusTagSkipped || result.Tag != "" {
+		t.Fatalf("Expected the tag to be skipped, got %+v (%v)", result, pushErr)
+	}
+}
+
+func TestPushChangesWithLease(t *testing.T) {
+	remote := newTestRemote(t, map[string]string{"a.txt": "a\n"})
+	remoteRepo, openErr := gogit.PlainOpen(remote)
+	if openErr != nil {
+		t.Fatal(openErr)
+	}
+	mainRef, refErr := remoteRepo.Reference(plumbing.NewBranchReferenceName("main"), true)
+	if refErr != nil {
+		t.Fatal(refErr)
+	}
+	expected := mainRef.Hash().String()
+
+	invoked := false
+	_, invalidErr := PushChangesWithResult(func() (*GitRepository, error) {
+		invoked = true
+		return nil, nil
+	}, "main", noCredentials{}, PushOptions{Lease: "main"})
+	if !errors.Is(invalidErr, ErrInvalidLease) || invoked {
+		t.Fatalf("Expected an ErrInvalidLease error before the hook is invoked, got: %v", invalidErr)
+	}
+
+	stale := commitInMemory(t, remote, "stale.txt", "stale\n")
+	concurrent := commitInMemory(t, remote, "concurrent.txt", "concurrent\n")
+	if _, err := PushChangesWithResult(pushHook(concurrent), "main", noCredentials{}, PushOptions{Lease: expected}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, rejectedErr := PushChangesWithResult(pushHook(stale), "main", noCredentials{}, PushOptions{Lease: expected})
+	var leaseErr *LeaseRejectedError
+	if !errors.As(rejectedErr, &leaseErr) || leaseErr.Err == nil || errors.Unwrap(leaseErr) != leaseErr.Err {
+		t.Fatalf("Expected a *LeaseRejectedError wrapping the push error, got: %v", rejectedErr)
+	}
+	assertTestFiles(t, getTestRemoteBranchFiles(t, remote, "main"), map[string]string{
+		"a.txt":          "a\n",
+		"concurrent.txt": "concurrent\n",
+	})
+}
+
+func getTestSignatureHash(t *testing.T, commit *object.Commit) crypto.Hash {
+	t.Helper()
+
+	block, decodeErr := armor.Decode(strings.NewReader(commit.PGPSignature))
+	if decodeErr != nil {
+		t.Fatal(decodeErr)
+	}
+	pkt, readErr := packet.Read(block.Body)
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	signature, ok := pkt.(*packet.Signature)
+	if !ok {
+		t.Fatalf("Expected a signature packet, got %T", pkt)
+	}
+
+	return signature.Hash
+}
+
+func TestCommitFilesWithSignatureHash(t *testing.T) {
+	files := map[string]string{"a.txt": "a\n", "sub.txt": "sub\n", "sub/b.txt": "b\n", "sub/c/d.txt": "d\n"}
+	key := newTestSignatureKey(t, "Test Author", "author@example.com")
+	opts := testCommitOptions
+	opts.SignatureKey = key
+	opts.SignatureHash = crypto.SHA512
+
+	dir := t.TempDir()
+	repo, initErr := InitRepo(dir, "main")
+	if initErr != nil {
+		t.Fatal(initErr)
+	}
+
+	paths := writeTestFiles(t, dir, files)
+	if _, err := CommitFiles(repo, paths, "Initial commit", opts); err != nil {
+		t.Fatal(err)
+	}
+	initial := getTestHeadCommit(t, repo)
+
+	//The tree built from the index is the same as the one go-git builds when it commits
+	unsigned, _ := newTestRepo(t, files)
+	if initial.TreeHash != getTestHeadCommit(t, unsigned).TreeHash {
+		t.Fatalf("Expected tree %s, got %s", getTestHeadCommit(t, unsigned).TreeHash, initial.TreeHash)
+	}
+
+	paths = writeTestFiles(t, dir, map[string]string{"sub/b.txt": "changed\n"})
+	if _, err := CommitFiles(repo, paths, "Change b.txt", opts); err != nil {
+		t.Fatal(err)
+	}
+	head := getTestHeadCommit(t, repo)
+	if len(head.ParentHashes) != 1 || head.ParentHashes[0] != initial.Hash {
+		t.Fatalf("Expected the commit to have the initial commit as parent, got %v", head.ParentHashes)
+	}
+	assertTestFiles(t, getTestTreeFiles(t, head), map[string]string{"a.txt": "a\n", "sub.txt": "sub\n", "sub/b.txt": "changed\n", "sub/c/d.txt": "d\n"})
+
+	for _, commit := range []*object.Commit{initial, head} {
+		if hash := getTestSignatureHash(t, commit); hash != crypto.SHA512 {
+			t.Fatalf("Expected commit %s to be signed with %s, got %s", commit.Hash, crypto.SHA512, hash)
+		}
+	}
+	if err := VerifyTopCommit(repo, []string{getTestArmoredPublicKey(t, key)}); err != nil {
+		t.Fatal(err)
+	}
+
+	//No unsigned commit is left behind in the store
+	commits, iterErr := repo.Repo.CommitObjects()
+	if iterErr != nil {
+		t.Fatal(iterErr)
+	}
+	count := 0
+	if err := commits.ForEach(func(*object.Commit) error { count++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("Expected 2 commits in the store, got %d", count)
+	}
+}
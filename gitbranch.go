@@ -0,0 +1,645 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/util"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+/*
+Error returned by the commit and push functions when HEAD is detached, unless the AllowDetachedHead option is set
+*/
+var ErrDetachedHead = errors.New("HEAD is detached: commits would not belong to any branch.")
+
+/*
+Returns whether HEAD of the git repository is detached, ie, whether it points directly to a commit instead of a branch.
+*/
+func IsDetachedHead(repo *GitRepository) (bool, error) {
+	head, headErr := repo.Repo.Reference(plumbing.HEAD, false)
+	if headErr != nil {
+		return false, fmt.Errorf("Error accessing repo head: %w", headErr)
+	}
+
+	return head.Type() == plumbing.HashReference, nil
+}
+
+/*
+Creates a new local branch pointing to the top commit of the git repository and checks it out, keeping any uncommitted changes in the worktree.
+Commits made on the branch can then be pushed with PushChanges, which will create the branch on origin if it doesn't exist there yet.
+*/
+func CreateBranch(repo *GitRepository, branch string) error {
+	head, headErr := repo.Repo.Head()
+	if headErr != nil {
+		return fmt.Errorf("Error accessing repo head: %w", headErr)
+	}
+
+	branchRef := plumbing.NewBranchReferenceName(branch)
+	_, refErr := repo.Repo.Reference(branchRef, false)
+	if refErr == nil {
+		return fmt.Errorf("Branch \"%s\" already exists", branch)
+	}
+	if refErr != plumbing.ErrReferenceNotFound {
+		return fmt.Errorf("Error accessing branch \"%s\": %w", branch, refErr)
+	}
+
+	w, wErr := repo.Repo.Worktree()
+	if wErr != nil {
+		return fmt.Errorf("Error accessing repo worktree: %w", wErr)
+	}
+
+	checkoutErr := w.Checkout(&gogit.CheckoutOptions{
+		Hash:   head.Hash(),
+		Branch: branchRef,
+		Create: true,
+		Keep:   true,
+	})
+	if checkoutErr != nil {
+		return fmt.Errorf("Error creating branch \"%s\": %w", branch, checkoutErr)
+	}
+
+	logger.Printf("Created branch \"%s\" at commit %s", branch, head.Hash())
+	repo.recordOperation(CheckoutOperation, fmt.Sprintf("Created and checked out branch \"%s\"", branch))
+	return nil
+}
+
+/*
+Error returned by CheckoutBranch when untracked files of the worktree would be overwritten by files tracked on the branch being checked out.
+It lists the colliding paths.
+*/
+type UntrackedCollisionError struct {
+	Paths []string
+}
+
+func (e *UntrackedCollisionError) Error() string {
+	return fmt.Sprintf("Checkout would overwrite the following untracked files: %s", strings.Join(e.Paths, ", "))
+}
+
+/*
+Optional parameters to pass to the CheckoutBranch command
+*/
+type CheckoutOptions struct {
+	//If true, untracked files of the worktree that collide with files tracked on the branch are overwritten instead of causing an error
+	Force bool
+}
+
+//The files of the branch are checked against the worktree filesystem directly, as the status of the worktree omits ignored files which would be overwritten as well
+func getUntrackedCollisions(repo *GitRepository, w *gogit.Worktree, commit *object.Commit) ([]string, error) {
+	idx, idxErr := repo.Repo.Storer.Index()
+	if idxErr != nil {
+		return nil, fmt.Errorf("Error accessing repo index: %w", idxErr)
+	}
+
+	tracked := map[string]bool{}
+	for _, entry := range idx.Entries {
+		tracked[entry.Name] = true
+	}
+
+	files, filesErr := commit.Files()
+	if filesErr != nil {
+		return nil, fmt.Errorf("Error accessing files of commit \"%s\": %w", commit.Hash, filesErr)
+	}
+	defer files.Close()
+
+	collisions := map[string]bool{}
+	iterErr := files.ForEach(func(file *object.File) error {
+		//An untracked file in place of one of the parent directories of the file collides with it as well
+		components := strings.Split(file.Name, "/")
+		for i := range components {
+			candidate := strings.Join(components[:i+1], "/")
+			if tracked[candidate] {
+				continue
+			}
+
+			info, statErr := w.Filesystem.Lstat(candidate)
+			if statErr != nil {
+				if os.IsNotExist(statErr) {
+					return nil
+				}
+				return fmt.Errorf("Error accessing file %s in worktree: %w", candidate, statErr)
+			}
+
+			if candidate == file.Name || !info.IsDir() {
+				collisions[candidate] = true
+				return nil
+			}
+		}
+
+		return nil
+	})
+	if iterErr != nil {
+		return nil, iterErr
+	}
+
+	sorted := []string{}
+	for collision := range collisions {
+		sorted = append(sorted, collision)
+	}
+	sort.Strings(sorted)
+
+	return sorted, nil
+}
+
+/*
+Checks out an existing local branch of the git repository.
+If untracked files of the worktree would be overwritten by files tracked on the branch (ex: generated files left behind by a previous run),
+an *UntrackedCollisionError listing them is returned, unless the Force option is set in which case they are overwritten.
+*/
+func CheckoutBranch(repo *GitRepository, branch string, opts CheckoutOptions) error {
+	branchRef := plumbing.NewBranchReferenceName(branch)
+	ref, refErr := repo.Repo.Reference(branchRef, true)
+	if refErr != nil {
+		return fmt.Errorf("Error accessing branch \"%s\": %w", branch, refErr)
+	}
+
+	commit, commitErr := repo.Repo.CommitObject(ref.Hash())
+	if commitErr != nil {
+		return fmt.Errorf("Error accessing top commit of branch \"%s\": %w", branch, commitErr)
+	}
+
+	w, wErr := repo.Repo.Worktree()
+	if wErr != nil {
+		return fmt.Errorf("Error accessing repo worktree: %w", wErr)
+	}
+
+	collisions, collisionsErr := getUntrackedCollisions(repo, w, commit)
+	if collisionsErr != nil {
+		return collisionsErr
+	}
+
+	if len(collisions) > 0 {
+		if !opts.Force {
+			return &UntrackedCollisionError{Paths: collisions}
+		}
+
+		for _, collision := range collisions {
+			removeErr := util.RemoveAll(w.Filesystem, collision)
+			if removeErr != nil {
+				return fmt.Errorf("Error removing untracked file %s: %w", collision, removeErr)
+			}
+		}
+	}
+
+	checkoutErr := w.Checkout(&gogit.CheckoutOptions{
+		Branch: branchRef,
+	})
+	if checkoutErr != nil {
+		return fmt.Errorf("Error checking out branch \"%s\": %w", branch, checkoutErr)
+	}
+
+	logger.Printf("Checked out branch \"%s\" at commit %s", branch, commit.Hash)
+	repo.recordOperation(CheckoutOperation, fmt.Sprintf("Checked out branch \"%s\"", branch))
+	return nil
+}
+
+/*
+Tracking configuration of a local branch
+*/
+type BranchConfig struct {
+	//Name of the local branch
+	Name   string
+	//Name of the remote the branch tracks
+	Remote string
+	//Reference on the remote the branch tracks (ex: refs/heads/main)
+	Merge  string
+}
+
+/*
+Returns the tracking configuration of all the local branches configured in the git repository, sorted by branch name.
+*/
+func GetBranchConfigs(repo *GitRepository) ([]BranchConfig, error) {
+	conf, confErr := repo.Repo.Config()
+	if confErr != nil {
+		return nil, fmt.Errorf("Error accessing repo configuration: %w", confErr)
+	}
+
+	branchConfigs := []BranchConfig{}
+	for _, branch := range conf.Branches {
+		branchConfigs = append(branchConfigs, BranchConfig{
+			Name:   branch.Name,
+			Remote: branch.Remote,
+			Merge:  branch.Merge.String(),
+		})
+	}
+
+	sort.Slice(branchConfigs, func(i, j int) bool {
+		return branchConfigs[i].Name < branchConfigs[j].Name
+	})
+
+	return branchConfigs, nil
+}
+
+/*
+Outcome of committing and pushing changes to one of the branches passed to CommitToBranches
+*/
+type BranchCommitResult struct {
+	Branch    string
+	//Whether a commit was made on the branch. No commit is made if the branch already had the changes
+	Committed bool
+	//Hash of the top commit of the branch after the operation
+	Commit    string
+	//Error that occurred on the branch, if any
+	Err       error
+}
+
+type fileSnapshot struct {
+	content []byte
+	mode    os.FileMode
+	exists  bool
+}
+
+func snapshotFiles(w *gogit.Worktree, files []string) (map[string]fileSnapshot, error) {
+	snapshots := map[string]fileSnapshot{}
+	for _, file := range files {
+		info, statErr := w.Filesystem.Lstat(file)
+		if statErr != nil {
+			if !os.IsNotExist(statErr) {
+				return nil, fmt.Errorf("Error accessing file %s in worktree: %w", file, statErr)
+			}
+
+			snapshots[file] = fileSnapshot{exists: false}
+			continue
+		}
+
+		content, readErr := readWorktreeFile(w, file)
+		if readErr != nil {
+			return nil, readErr
+		}
+
+		snapshots[file] = fileSnapshot{content: content, mode: info.Mode(), exists: true}
+	}
+
+	return snapshots, nil
+}
+
+func restoreFiles(w *gogit.Worktree, snapshots map[string]fileSnapshot) error {
+	for file, snapshot := range snapshots {
+		if !snapshot.exists {
+			removeErr := w.Filesystem.Remove(file)
+			if removeErr != nil && !os.IsNotExist(removeErr) {
+				return fmt.Errorf("Error removing file %s from worktree: %w", file, removeErr)
+			}
+			continue
+		}
+
+		writeErr := writeWorktreeFile(w, file, snapshot.content, snapshot.mode)
+		if writeErr != nil {
+			return writeErr
+		}
+	}
+
+	return nil
+}
+
+/*
+Error returned by CommitToBranches when the worktree has uncommitted changes to files other than the ones to commit, which checking out the branches would discard.
+It lists the paths of the changed files.
+*/
+type UncommittedChangesError struct {
+	Paths []string
+}
+
+func (e *UncommittedChangesError) Error() string {
+	return fmt.Sprintf("Worktree has uncommitted changes to the following files: %s", strings.Join(e.Paths, ", "))
+}
+
+/*
+Optional parameters to pass to the CommitToBranchesWithOptions command
+*/
+type CommitToBranchesOptions struct {
+	//Options used to push each branch (ex: to retry pushes rejected because a branch was updated on origin in the meantime)
+	Push  PushOptions
+	//If true, uncommitted changes to files other than the given ones and commits of the local branches that are not on origin are discarded instead of causing an error
+	Force bool
+}
+
+func getUncommittedChanges(w *gogit.Worktree, files []string) ([]string, error) {
+	stat, statErr := w.Status()
+	if statErr != nil {
+		return nil, fmt.Errorf("Error getting repo status: %w", statErr)
+	}
+
+	committed := map[string]bool{}
+	for _, file := range files {
+		committed[file] = true
+	}
+
+	changes := []string{}
+	for file, fileStat := range stat {
+		if committed[file] {
+			continue
+		}
+
+		if fileStat.Worktree != gogit.Unmodified || fileStat.Staging != gogit.Unmodified {
+			changes = append(changes, file)
+		}
+	}
+	sort.Strings(changes)
+
+	return changes, nil
+}
+
+func fetchOriginBranch(repo *GitRepository, branch string, creds Credentials) (*plumbing.Reference, error) {
+	remoteRef := plumbing.NewRemoteReferenceName("origin", branch)
+	fetchErr := FetchRefSpecs(repo, []string{fmt.Sprintf("+%s:%s", plumbing.NewBranchReferenceName(branch), remoteRef)}, creds)
+	if fetchErr != nil {
+		return nil, fmt.Errorf("Error fetching branch \"%s\" from origin: %w", branch, fetchErr)
+	}
+
+	remote, refErr := repo.Repo.Reference(remoteRef, true)
+	if refErr != nil {
+		return nil, fmt.Errorf("Error accessing branch \"%s\" of origin: %w", branch, refErr)
+	}
+
+	return remote, nil
+}
+
+//The local branch can only be reset to its head on origin without losing anything if all its commits are on origin
+func checkNoUnpushedCommits(repo *GitRepository, branch string, creds Credentials) error {
+	local, localErr := repo.Repo.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if localErr == plumbing.ErrReferenceNotFound {
+		return nil
+	}
+	if localErr != nil {
+		return fmt.Errorf("Error accessing branch \"%s\": %w", branch, localErr)
+	}
+
+	remote, remoteErr := fetchOriginBranch(repo, branch, creds)
+	if remoteErr != nil {
+		return remoteErr
+	}
+
+	if local.Hash() == remote.Hash() {
+		return nil
+	}
+
+	localCommit, localCommitErr := repo.Repo.CommitObject(local.Hash())
+	if localCommitErr != nil {
+		return fmt.Errorf("Error accessing top commit of branch \"%s\": %w", branch, localCommitErr)
+	}
+
+	remoteCommit, remoteCommitErr := repo.Repo.CommitObject(remote.Hash())
+	if remoteCommitErr != nil {
+		return fmt.Errorf("Error accessing top commit of branch \"%s\" of origin: %w", branch, remoteCommitErr)
+	}
+
+	isAncestor, ancestorErr := localCommit.IsAncestor(remoteCommit)
+	if ancestorErr != nil {
+		return fmt.Errorf("Error comparing branch \"%s\" with origin: %w", branch, ancestorErr)
+	}
+
+	if !isAncestor {
+		return fmt.Errorf("Branch \"%s\" has commits that are not on origin", branch)
+	}
+
+	return nil
+}
+
+//Reverts the given files to their content in the top commit, so that checkouts don't see them as uncommitted changes. Their content is kept in the snapshots
+func revertFiles(repo *GitRepository, w *gogit.Worktree, files []string) error {
+	commit, commitErr := getHeadCommit(repo)
+	if commitErr != nil {
+		return commitErr
+	}
+
+	for _, file := range files {
+		unstageErr := unstageFile(repo, file)
+		if unstageErr != nil {
+			return unstageErr
+		}
+
+		headFile, fileErr := commit.File(file)
+		if fileErr == object.ErrFileNotFound {
+			removeErr := w.Filesystem.Remove(file)
+			if removeErr != nil && !os.IsNotExist(removeErr) {
+				return fmt.Errorf("Error removing file %s from worktree: %w", file, removeErr)
+			}
+			continue
+		}
+		if fileErr != nil {
+			return fmt.Errorf("Error accessing file %s of repo top commit: %w", file, fileErr)
+		}
+
+		content, contentErr := headFile.Contents()
+		if contentErr != nil {
+			return fmt.Errorf("Error reading file %s of repo top commit: %w", file, contentErr)
+		}
+
+		mode, modeErr := headFile.Mode.ToOSFileMode()
+		if modeErr != nil {
+			return fmt.Errorf("Error reading mode of file %s of repo top commit: %w", file, modeErr)
+		}
+
+		writeErr := writeWorktreeFile(w, file, []byte(content), mode)
+		if writeErr != nil {
+			return writeErr
+		}
+	}
+
+	return nil
+}
+
+//Resets the branch to its head on origin, so that a stale local branch never causes a non-fast-forward push, and commits the files on it
+func commitOnOriginBranch(repo *GitRepository, w *gogit.Worktree, branch string, snapshots map[string]fileSnapshot, files []string, msg string, opts CommitOptions, creds Credentials, force bool) (bool, error) {
+	remote, remoteErr := fetchOriginBranch(repo, branch, creds)
+	if remoteErr != nil {
+		return false, remoteErr
+	}
+
+	//The files are reverted before the branch is moved, as they are reverted to their content in the top commit
+	revertErr := revertFiles(repo, w, files)
+	if revertErr != nil {
+		return false, revertErr
+	}
+
+	branchRef := plumbing.NewBranchReferenceName(branch)
+	setErr := repo.Repo.Storer.SetReference(plumbing.NewHashReference(branchRef, remote.Hash()))
+	if setErr != nil {
+		return false, fmt.Errorf("Error resetting branch \"%s\" to origin: %w", branch, setErr)
+	}
+
+	checkoutErr := w.Checkout(&gogit.CheckoutOptions{
+		Branch: branchRef,
+		Force:  force,
+	})
+	if checkoutErr != nil {
+		return false, fmt.Errorf("Error checking out branch \"%s\": %w", branch, checkoutErr)
+	}
+
+	restoreErr := restoreFiles(w, snapshots)
+	if restoreErr != nil {
+		return false, restoreErr
+	}
+
+	return CommitFiles(repo, files, msg, opts)
+}
+
+func commitToBranch(repo *GitRepository, w *gogit.Worktree, branch string, snapshots map[string]fileSnapshot, files []string, msg string, opts CommitOptions, creds Credentials, cmdOpts CommitToBranchesOptions) BranchCommitResult {
+	result := BranchCommitResult{Branch: branch}
+
+	//The commit is made again on top of the new head of the branch on origin each time the push is retried
+	_, pushErr := PushChangesWithResult(func() (*GitRepository, error) {
+		committed, commitErr := commitOnOriginBranch(repo, w, branch, snapshots, files, msg, opts, creds, cmdOpts.Force)
+		result.Committed = committed
+		if commitErr != nil || !committed {
+			return nil, commitErr
+		}
+
+		return repo, nil
+	}, branch, creds, cmdOpts.Push)
+	if pushErr != nil {
+		result.Err = pushErr
+		return result
+	}
+
+	head, headErr := repo.Repo.Head()
+	if headErr != nil {
+		result.Err = fmt.Errorf("Error accessing repo head: %w", headErr)
+		return result
+	}
+	result.Commit = head.Hash().String()
+
+	return result
+}
+
+/*
+Commits the given files, with their content in the current worktree, on each of the given branches and pushes each branch to origin.
+Each branch is moved to its head on origin before the files are committed on it.
+Before any branch is touched, an *UncommittedChangesError is returned if the worktree has uncommitted changes to files other than the given ones
+and an error is returned if one of the local branches has commits that are not on origin, as both would be lost.
+A failure on a branch doesn't prevent the other branches from being processed:
+the outcome of each branch is returned and an error listing the branches that failed is returned as well if any did.
+Once done, the branch that was initially checked out is checked out again with the files as they were in the worktree.
+Pushes are not retried. Use CommitToBranchesWithOptions to retry them when the branches are updated concurrently.
+*/
+func CommitToBranches(repo *GitRepository, branches []string, files []string, msg string, opts CommitOptions, creds Credentials) ([]BranchCommitResult, error) {
+	return CommitToBranchesWithOptions(repo, branches, files, msg, opts, creds, CommitToBranchesOptions{})
+}
+
+/*
+Same as CommitToBranches, but with additional options to retry pushes or to discard uncommitted changes and unpushed commits that would otherwise cause an error.
+On each retry, the branch is moved to its new head on origin and the files are committed on it again.
+*/
+func CommitToBranchesWithOptions(repo *GitRepository, branches []string, files []string, msg string, opts CommitOptions, creds Credentials, cmdOpts CommitToBranchesOptions) ([]BranchCommitResult, error) {
+	w, wErr := repo.Repo.Worktree()
+	if wErr != nil {
+		return nil, fmt.Errorf("Error accessing repo worktree: %w", wErr)
+	}
+
+	head, headErr := repo.Repo.Head()
+	if headErr != nil {
+		return nil, fmt.Errorf("Error accessing repo head: %w", headErr)
+	}
+
+	if !cmdOpts.Force {
+		changes, changesErr := getUncommittedChanges(w, files)
+		if changesErr != nil {
+			return nil, changesErr
+		}
+
+		if len(changes) > 0 {
+			return nil, &UncommittedChangesError{Paths: changes}
+		}
+
+		for _, branch := range branches {
+			unpushedErr := checkNoUnpushedCommits(repo, branch, creds)
+			if unpushedErr != nil {
+				return nil, unpushedErr
+			}
+		}
+	}
+
+	snapshots, snapshotErr := snapshotFiles(w, files)
+	if snapshotErr != nil {
+		return nil, snapshotErr
+	}
+
+	results := []BranchCommitResult{}
+	failed := []string{}
+	for _, branch := range branches {
+		result := commitToBranch(repo, w, branch, snapshots, files, msg, opts, creds, cmdOpts)
+		if result.Err != nil {
+			logger.Printf("Failed to commit changes on branch \"%s\": %s", branch, result.Err.Error())
+			failed = append(failed, branch)
+		}
+		results = append(results, result)
+	}
+
+	revertErr := revertFiles(repo, w, files)
+	if revertErr != nil {
+		return results, revertErr
+	}
+
+	checkoutOpts := gogit.CheckoutOptions{Force: cmdOpts.Force}
+	if head.Name().IsBranch() {
+		checkoutOpts.Branch = head.Name()
+	} else {
+		checkoutOpts.Hash = head.Hash()
+	}
+	checkoutErr := w.Checkout(&checkoutOpts)
+	if checkoutErr != nil {
+		return results, fmt.Errorf("Error checking out initial reference \"%s\" again: %w", head.Name().Short(), checkoutErr)
+	}
+
+	restoreErr := restoreFiles(w, snapshots)
+	if restoreErr != nil {
+		return results, restoreErr
+	}
+
+	if len(failed) > 0 {
+		return results, fmt.Errorf("Failed to commit changes on the following branches: %s", strings.Join(failed, ", "))
+	}
+
+	return results, nil
+}
+
+/*
+Fetches the head of the pull request (or merge request) with the given number from origin and checks it out on a detached HEAD.
+The provider determines where the pull request is advertised: "github" (refs/pull/<number>/head) or "gitlab" (refs/merge-requests/<number>/head).
+The fetched head is kept under refs/remotes/origin/pr/<number>. Uncommitted changes to tracked files are discarded by the checkout.
+*/
+func CheckoutPullRequest(repo *GitRepository, number int, provider string, creds Credentials) error {
+	var remoteRef string
+	switch provider {
+	case "github":
+		remoteRef = fmt.Sprintf("refs/pull/%d/head", number)
+	case "gitlab":
+		remoteRef = fmt.Sprintf("refs/merge-requests/%d/head", number)
+	default:
+		return fmt.Errorf("Unsupported pull request provider \"%s\": it should be either \"github\" or \"gitlab\"", provider)
+	}
+
+	localRef := plumbing.NewRemoteReferenceName("origin", fmt.Sprintf("pr/%d", number))
+	fetchErr := FetchRefSpecs(repo, []string{fmt.Sprintf("+%s:%s", remoteRef, localRef)}, creds)
+	if fetchErr != nil {
+		return fetchErr
+	}
+
+	ref, refErr := repo.Repo.Reference(localRef, true)
+	if refErr != nil {
+		return fmt.Errorf("Error accessing fetched head of pull request %d: %w", number, refErr)
+	}
+
+	w, wErr := repo.Repo.Worktree()
+	if wErr != nil {
+		return fmt.Errorf("Error accessing repo worktree: %w", wErr)
+	}
+
+	checkoutErr := w.Checkout(&gogit.CheckoutOptions{
+		Hash:  ref.Hash(),
+		Force: true,
+	})
+	if checkoutErr != nil {
+		return fmt.Errorf("Error checking out head of pull request %d: %w", number, checkoutErr)
+	}
+
+	logger.Printf("Checked out pull request %d at commit %s", number, ref.Hash())
+	repo.recordOperation(CheckoutOperation, fmt.Sprintf("Checked out pull request %d", number))
+	return nil
+}
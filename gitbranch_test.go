@@ -0,0 +1,230 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestCreateBranchPushesNewBranch(t *testing.T) {
+	remote := newTestRemote(t, map[string]string{"a.txt": "a\n"})
+	dir := filepath.Join(t.TempDir(), "clone")
+	repo, _, syncErr := SyncGitRepo(dir, remote, "main", noCredentials{})
+	if syncErr != nil {
+		t.Fatal(syncErr)
+	}
+
+	if err := CreateBranch(repo, "feature"); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateBranch(repo, "feature"); err == nil {
+		t.Fatal("Expected an error creating a branch that already exists")
+	}
+
+	paths := writeTestFiles(t, dir, map[string]string{"b.txt": "b\n"})
+	if _, err := CommitFiles(repo, paths, "Add b.txt on feature", testCommitOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := PushChanges(pushHook(repo), "feature", noCredentials{}, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	assertTestFiles(t, getTestRemoteBranchFiles(t, remote, "feature"), map[string]string{
+		"a.txt": "a\n",
+		"b.txt": "b\n",
+	})
+	assertTestFiles(t, getTestRemoteBranchFiles(t, remote, "main"), map[string]string{
+		"a.txt": "a\n",
+	})
+}
+
+func setTestRemoteBranch(t *testing.T, remote string, branch string, from string) {
+	t.Helper()
+
+	remoteRepo, openErr := gogit.PlainOpen(remote)
+	if openErr != nil {
+		t.Fatal(openErr)
+	}
+	ref, refErr := remoteRepo.Reference(plumbing.NewBranchReferenceName(from), true)
+	if refErr != nil {
+		t.Fatal(refErr)
+	}
+	if err := remoteRepo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName(branch), ref.Hash())); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommitToBranchesFastForwardsStaleBranches(t *testing.T) {
+	remote := newTestRemote(t, map[string]string{"a.txt": "a\n"})
+	setTestRemoteBranch(t, remote, "release", "main")
+
+	dir := filepath.Join(t.TempDir(), "clone")
+	repo, _, syncErr := SyncGitRepo(dir, remote, "main", noCredentials{})
+	if syncErr != nil {
+		t.Fatal(syncErr)
+	}
+	if err := FetchRefSpecs(repo, []string{"+refs/heads/release:refs/heads/release"}, noCredentials{}); err != nil {
+		t.Fatal(err)
+	}
+
+	//The release branch moves on origin after it was fetched, so the local branch is stale
+	concurrent := commitInMemory(t, remote, "concurrent.txt", "concurrent\n")
+	if err := PushChanges(pushHook(concurrent), "main", noCredentials{}, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	setTestRemoteBranch(t, remote, "release", "main")
+
+	paths := writeTestFiles(t, dir, map[string]string{"config.yml": "version: 2\n"})
+	results, commitErr := CommitToBranchesWithOptions(repo, []string{"release"}, paths, "Update config", testCommitOptions, noCredentials{}, CommitToBranchesOptions{Push: PushOptions{Retries: 2}})
+	if commitErr != nil {
+		t.Fatal(commitErr)
+	}
+	if len(results) != 1 || !results[0].Committed || results[0].Err != nil {
+		t.Fatalf("Expected the change to be committed on the release branch, got %+v", results)
+	}
+
+	assertTestFiles(t, getTestRemoteBranchFiles(t, remote, "release"), map[string]string{
+		"a.txt":          "a\n",
+		"concurrent.txt": "concurrent\n",
+		"config.yml":     "version: 2\n",
+	})
+
+	head := getTestHeadCommit(t, repo)
+	if head.Hash.String() == results[0].Commit {
+		t.Fatal("Expected the initial branch to be checked out again")
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "config.yml")); err != nil || string(content) != "version: 2\n" {
+		t.Fatalf("Expected the files to be restored in the worktree, got %q (%v)", content, err)
+	}
+}
+
+func TestCommitToBranchesRejectsUncommittedChanges(t *testing.T) {
+	remote := newTestRemote(t, map[string]string{"a.txt": "a\n"})
+	setTestRemoteBranch(t, remote, "release", "main")
+
+	dir := filepath.Join(t.TempDir(), "clone")
+	repo, _, syncErr := SyncGitRepo(dir, remote, "main", noCredentials{})
+	if syncErr != nil {
+		t.Fatal(syncErr)
+	}
+
+	writeTestFiles(t, dir, map[string]string{"a.txt": "local edit\n"})
+	paths := writeTestFiles(t, dir, map[string]string{"config.yml": "version: 2\n"})
+	_, commitErr := CommitToBranches(repo, []string{"release"}, paths, "Update config", testCommitOptions, noCredentials{})
+
+	var changesErr *UncommittedChangesError
+	if !errors.As(commitErr, &changesErr) || strings.Join(changesErr.Paths, ",") != "a.txt" {
+		t.Fatalf("Expected an uncommitted changes error for a.txt, got %v", commitErr)
+	}
+
+	for file, expected := range map[string]string{"a.txt": "local edit\n", "config.yml": "version: 2\n"} {
+		if content, err := os.ReadFile(filepath.Join(dir, file)); err != nil || string(content) != expected {
+			t.Fatalf("Expected file %s to be left as it was, got %q (%v)", file, content, err)
+		}
+	}
+	assertTestFiles(t, getTestRemoteBranchFiles(t, remote, "release"), map[string]string{
+		"a.txt": "a\n",
+	})
+}
+
+func TestCommitToBranchesRejectsUnpushedCommits(t *testing.T) {
+	remote := newTestRemote(t, map[string]string{"a.txt": "a\n"})
+	setTestRemoteBranch(t, remote, "release", "main")
+
+	dir := filepath.Join(t.TempDir(), "clone")
+	repo, _, syncErr := SyncGitRepo(dir, remote, "main", noCredentials{})
+	if syncErr != nil {
+		t.Fatal(syncErr)
+	}
+	if err := FetchRefSpecs(repo, []string{"+refs/heads/release:refs/heads/release"}, noCredentials{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckoutBranch(repo, "release", CheckoutOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	localPaths := writeTestFiles(t, dir, map[string]string{"local.txt": "local\n"})
+	if _, err := CommitFiles(repo, localPaths, "Local commit", testCommitOptions); err != nil {
+		t.Fatal(err)
+	}
+	unpushed := getTestHeadCommit(t, repo).Hash
+	if err := CheckoutBranch(repo, "main", CheckoutOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	paths := writeTestFiles(t, dir, map[string]string{"config.yml": "version: 2\n"})
+	if _, err := CommitToBranches(repo, []string{"release"}, paths, "Update config", testCommitOptions, noCredentials{}); err == nil || !strings.Contains(err.Error(), "not on origin") {
+		t.Fatalf("Expected an error about the commits of the release branch that are not on origin, got %v", err)
+	}
+
+	release, releaseErr := repo.Repo.Reference(plumbing.NewBranchReferenceName("release"), true)
+	if releaseErr != nil {
+		t.Fatal(releaseErr)
+	}
+	if release.Hash() != unpushed {
+		t.Fatalf("Expected the release branch to be left at commit %s, got %s", unpushed, release.Hash())
+	}
+
+	//With the Force option, the unpushed commit is discarded
+	results, commitErr := CommitToBranchesWithOptions(repo, []string{"release"}, paths, "Update config", testCommitOptions, noCredentials{}, CommitToBranchesOptions{Force: true})
+	if commitErr != nil {
+		t.Fatal(commitErr)
+	}
+	if len(results) != 1 || !results[0].Committed {
+		t.Fatalf("Expected the change to be committed on the release branch, got %+v", results)
+	}
+	assertTestFiles(t, getTestRemoteBranchFiles(t, remote, "release"), map[string]string{
+		"a.txt":      "a\n",
+		"config.yml": "version: 2\n",
+	})
+}
+
+func TestCheckoutBranchDetectsIgnoredCollisions(t *testing.T) {
+	repo, dir := newTestRepo(t, map[string]string{".gitignore": "*.log\nbuild/\n"})
+	if err := CreateBranch(repo, "feature"); err != nil {
+		t.Fatal(err)
+	}
+	paths := writeTestFiles(t, dir, map[string]string{"debug.log": "tracked on feature\n", "generated.txt": "generated\n"})
+	//The ignored file is force-added, like with "git add -f"
+	w, wErr := repo.Repo.Worktree()
+	if wErr != nil {
+		t.Fatal(wErr)
+	}
+	for _, filePath := range paths {
+		if _, err := w.Add(filePath); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := w.Commit("Add files on feature", &gogit.CommitOptions{Author: &object.Signature{Name: "Test Author", Email: "author@example.com"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckoutBranch(repo, "main", CheckoutOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	//Leftovers of a previous run, one of which is ignored and thus absent from the status of the worktree
+	writeTestFiles(t, dir, map[string]string{"debug.log": "local\n", "generated.txt": "local\n"})
+
+	checkoutErr := CheckoutBranch(repo, "feature", CheckoutOptions{})
+	var collisionErr *UntrackedCollisionError
+	if !errors.As(checkoutErr, &collisionErr) || strings.Join(collisionErr.Paths, ",") != "debug.log,generated.txt" {
+		t.Fatalf("Expected collisions on debug.log and generated.txt, got: %v", checkoutErr)
+	}
+
+	if err := CheckoutBranch(repo, "feature", CheckoutOptions{Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "generated.txt")); err != nil || string(content) != "generated\n" {
+		t.Fatalf("Expected generated.txt to be overwritten by the branch, got %q (%v)", content, err)
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "debug.log")); err == nil && string(content) == "local\n" {
+		t.Fatal("Expected the ignored leftover debug.log to be removed")
+	}
+}
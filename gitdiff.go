@@ -0,0 +1,355 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+/*
+Nature of the change made to a file by a commit
+*/
+type ChangeStatus string
+
+const (
+	FileAdded    ChangeStatus = "added"
+	FileModified ChangeStatus = "modified"
+	FileDeleted  ChangeStatus = "deleted"
+)
+
+/*
+File changed by a commit, as returned by GetCommitChangedFiles
+*/
+type ChangedFile struct {
+	Path   string
+	Status ChangeStatus
+}
+
+func getChangedPaths(from *object.Commit, to *object.Commit) (map[string]bool, error) {
+	fromTree, fromErr := from.Tree()
+	if fromErr != nil {
+		return nil, fmt.Errorf("Error accessing tree of commit \"%s\": %w", from.Hash, fromErr)
+	}
+
+	toTree, toErr := to.Tree()
+	if toErr != nil {
+		return nil, fmt.Errorf("Error accessing tree of commit \"%s\": %w", to.Hash, toErr)
+	}
+
+	changes, diffErr := object.DiffTree(fromTree, toTree)
+	if diffErr != nil {
+		return nil, fmt.Errorf("Error computing changes between commits \"%s\" and \"%s\": %w", from.Hash, to.Hash, diffErr)
+	}
+
+	paths := map[string]bool{}
+	for _, change := range changes {
+		if change.From.Name != "" {
+			paths[change.From.Name] = true
+		}
+		if change.To.Name != "" {
+			paths[change.To.Name] = true
+		}
+	}
+
+	return paths, nil
+}
+
+/*
+Returns the files that the commit with the given hash changed relative to its first parent, with the nature of each change.
+For the root commit, all its files are returned as added.
+*/
+func GetCommitChangedFiles(repo *GitRepository, hash string) ([]ChangedFile, error) {
+	commit, commitErr := repo.Repo.CommitObject(plumbing.NewHash(hash))
+	if commitErr != nil {
+		return nil, fmt.Errorf("Error accessing commit \"%s\": %w", hash, commitErr)
+	}
+
+	tree, treeErr := commit.Tree()
+	if treeErr != nil {
+		return nil, fmt.Errorf("Error accessing tree of commit \"%s\": %w", hash, treeErr)
+	}
+
+	var parentTree *object.Tree
+	if commit.NumParents() > 0 {
+		parent, parentErr := commit.Parent(0)
+		if parentErr != nil {
+			return nil, fmt.Errorf("Error accessing parent of commit \"%s\": %w", hash, parentErr)
+		}
+
+		var parentTreeErr error
+		parentTree, parentTreeErr = parent.Tree()
+		if parentTreeErr != nil {
+			return nil, fmt.Errorf("Error accessing tree of commit \"%s\": %w", parent.Hash, parentTreeErr)
+		}
+	}
+
+	changes, diffErr := object.DiffTree(parentTree, tree)
+	if diffErr != nil {
+		return nil, fmt.Errorf("Error computing changes of commit \"%s\": %w", hash, diffErr)
+	}
+
+	changedFiles := []ChangedFile{}
+	for _, change := range changes {
+		action, actionErr := change.Action()
+		if actionErr != nil {
+			return nil, fmt.Errorf("Error computing changes of commit \"%s\": %w", hash, actionErr)
+		}
+
+		switch action {
+		case merkletrie.Insert:
+			changedFiles = append(changedFiles, ChangedFile{Path: change.To.Name, Status: FileAdded})
+		case merkletrie.Delete:
+			changedFiles = append(changedFiles, ChangedFile{Path: change.From.Name, Status: FileDeleted})
+		case merkletrie.Modify:
+			changedFiles = append(changedFiles, ChangedFile{Path: change.To.Name, Status: FileModified})
+		}
+	}
+
+	return changedFiles, nil
+}
+
+func getCommitAttributes(commit *object.Commit) (gitattributes.Matcher, error) {
+	files, filesErr := commit.Files()
+	if filesErr != nil {
+		return nil, fmt.Errorf("Error accessing files of commit \"%s\": %w", commit.Hash, filesErr)
+	}
+	defer files.Close()
+
+	attributesFiles := []*object.File{}
+	iterErr := files.ForEach(func(file *object.File) error {
+		if path.Base(file.Name) == ".gitattributes" {
+			attributesFiles = append(attributesFiles, file)
+		}
+		return nil
+	})
+	if iterErr != nil {
+		return nil, fmt.Errorf("Error iterating over files of commit \"%s\": %w", commit.Hash, iterErr)
+	}
+
+	//Attributes of deeper directories take precedence, so they must come last in the stack
+	sort.Slice(attributesFiles, func(i, j int) bool {
+		return strings.Count(attributesFiles[i].Name, "/") < strings.Count(attributesFiles[j].Name, "/")
+	})
+
+	stack := []gitattributes.MatchAttribute{}
+	for _, file := range attributesFiles {
+		content, contentErr := file.Contents()
+		if contentErr != nil {
+			return nil, fmt.Errorf("Error reading file %s: %w", file.Name, contentErr)
+		}
+
+		domain := []string{}
+		if dir := path.Dir(file.Name); dir != "." {
+			domain = strings.Split(dir, "/")
+		}
+
+		attributes, readErr := gitattributes.ReadAttributes(strings.NewReader(content), domain, file.Name == ".gitattributes")
+		if readErr != nil {
+			return nil, fmt.Errorf("Error parsing file %s: %w", file.Name, readErr)
+		}
+		stack = append(stack, attributes...)
+	}
+
+	return gitattributes.NewMatcher(stack), nil
+}
+
+type opaquePatch struct {
+	patch   fdiff.Patch
+	matcher gitattributes.Matcher
+}
+
+type opaqueFilePatch struct {
+	fdiff.FilePatch
+}
+
+func (p *opaqueFilePatch) IsBinary() bool {
+	return true
+}
+
+//The encoder writes the chunks of binary file patches after the "Binary files ... differ" line, so the content is hidden here
+func (p *opaqueFilePatch) Chunks() []fdiff.Chunk {
+	return nil
+}
+
+func (p *opaquePatch) Message() string {
+	return p.patch.Message()
+}
+
+func (p *opaquePatch) FilePatches() []fdiff.FilePatch {
+	filePatches := []fdiff.FilePatch{}
+	for _, filePatch := range p.patch.FilePatches() {
+		from, to := filePatch.Files()
+		file := to
+		if file == nil {
+			file = from
+		}
+
+		if file != nil {
+			attributes, matched := p.matcher.Match(strings.Split(file.Path(), "/"), []string{"diff"})
+			if matched && attributes["diff"] != nil && attributes["diff"].IsUnset() {
+				filePatch = &opaqueFilePatch{filePatch}
+			}
+		}
+
+		filePatches = append(filePatches, filePatch)
+	}
+
+	return filePatches
+}
+
+/*
+Returns the changes between the two given references (branches, tags, hashes, etc) in the unified diff format, like "git diff from to".
+Like git, changes to binary files, as well as to files marked with the "-diff" attribute in the .gitattributes files of the target reference,
+are summarized with a "Binary files ... differ" line instead of their content.
+Other attributes are ignored. In particular, "merge=union" is not supported: the sdk doesn't merge branches, as go-git cannot, so merge drivers have no effect.
+*/
+func DiffCommits(repo *GitRepository, from string, to string) (string, error) {
+	fromCommit, fromErr := resolveCommit(repo, from)
+	if fromErr != nil {
+		return "", fromErr
+	}
+
+	toCommit, toErr := resolveCommit(repo, to)
+	if toErr != nil {
+		return "", toErr
+	}
+
+	patch, patchErr := fromCommit.Patch(toCommit)
+	if patchErr != nil {
+		return "", fmt.Errorf("Error computing diff between \"%s\" and \"%s\": %w", from, to, patchErr)
+	}
+
+	matcher, matcherErr := getCommitAttributes(toCommit)
+	if matcherErr != nil {
+		return "", matcherErr
+	}
+
+	var buf bytes.Buffer
+	encErr := fdiff.NewUnifiedEncoder(&buf, fdiff.DefaultContextLines).Encode(&opaquePatch{patch, matcher})
+	if encErr != nil {
+		return "", fmt.Errorf("Error encoding diff between \"%s\" and \"%s\": %w", from, to, encErr)
+	}
+
+	return buf.String(), nil
+}
+
+const binarySniffLength = 8000
+
+/*
+Returns whether the file at the given path in the worktree of the git repository is binary.
+Like git, a file is considered binary if its first 8000 bytes contain a NUL byte.
+*/
+func IsBinaryFile(repo *GitRepository, filePath string) (bool, error) {
+	w, wErr := repo.Repo.Worktree()
+	if wErr != nil {
+		return false, fmt.Errorf("Error accessing repo worktree: %w", wErr)
+	}
+
+	content, readErr := readWorktreeFile(w, filePath)
+	if readErr != nil {
+		return false, readErr
+	}
+
+	return isBinaryContent(content), nil
+}
+
+func isBinaryContent(content []byte) bool {
+	if len(content) > binarySniffLength {
+		content = content[:binarySniffLength]
+	}
+
+	return bytes.IndexByte(content, 0) != -1
+}
+
+/*
+Compares the trees of the top commits of two git repositories (ex: a source and its mirror) and returns whether they are identical,
+along with the sorted paths of the files that differ. The repositories can be bare and don't need to share any history.
+*/
+func TreesEqual(repoA *GitRepository, repoB *GitRepository) (bool, []string, error) {
+	commitA, commitAErr := getHeadCommit(repoA)
+	if commitAErr != nil {
+		return false, nil, commitAErr
+	}
+
+	commitB, commitBErr := getHeadCommit(repoB)
+	if commitBErr != nil {
+		return false, nil, commitBErr
+	}
+
+	if commitA.TreeHash == commitB.TreeHash {
+		return true, []string{}, nil
+	}
+
+	paths, pathsErr := getChangedPaths(commitA, commitB)
+	if pathsErr != nil {
+		return false, nil, pathsErr
+	}
+
+	differences := []string{}
+	for filePath := range paths {
+		differences = append(differences, filePath)
+	}
+	sort.Strings(differences)
+
+	return len(differences) == 0, differences, nil
+}
+
+/*
+Returns the sorted paths of the files that exist in the "to" commit but not in the "from" commit (added)
+and the sorted paths of the files that exist in the "from" commit but not in the "to" commit (removed).
+Modified files are not returned, which makes it lighter than DiffCommits when only the set of files matters.
+*/
+func FilesAddedRemoved(repo *GitRepository, fromHash string, toHash string) ([]string, []string, error) {
+	fromCommit, fromErr := resolveCommit(repo, fromHash)
+	if fromErr != nil {
+		return nil, nil, fromErr
+	}
+
+	toCommit, toErr := resolveCommit(repo, toHash)
+	if toErr != nil {
+		return nil, nil, toErr
+	}
+
+	fromTree, fromTreeErr := fromCommit.Tree()
+	if fromTreeErr != nil {
+		return nil, nil, fmt.Errorf("Error accessing tree of commit \"%s\": %w", fromCommit.Hash, fromTreeErr)
+	}
+
+	toTree, toTreeErr := toCommit.Tree()
+	if toTreeErr != nil {
+		return nil, nil, fmt.Errorf("Error accessing tree of commit \"%s\": %w", toCommit.Hash, toTreeErr)
+	}
+
+	changes, diffErr := object.DiffTree(fromTree, toTree)
+	if diffErr != nil {
+		return nil, nil, fmt.Errorf("Error computing changes between commits \"%s\" and \"%s\": %w", fromCommit.Hash, toCommit.Hash, diffErr)
+	}
+
+	added := []string{}
+	removed := []string{}
+	for _, change := range changes {
+		action, actionErr := change.Action()
+		if actionErr != nil {
+			return nil, nil, fmt.Errorf("Error computing changes between commits \"%s\" and \"%s\": %w", fromCommit.Hash, toCommit.Hash, actionErr)
+		}
+
+		switch action {
+		case merkletrie.Insert:
+			added = append(added, change.To.Name)
+		case merkletrie.Delete:
+			removed = append(removed, change.From.Name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+
+	return added, removed, nil
+}
@@ -0,0 +1,32 @@
+package git
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffCommitsHonorsDiffAttribute(t *testing.T) {
+	repo, dir := newTestRepo(t, map[string]string{
+		".gitattributes": "*.lock -diff merge=union\n",
+		"deps.lock":      "a\n",
+		"config.yml":     "version: 1\n",
+	})
+	from := getTestHeadCommit(t, repo).Hash.String()
+
+	paths := writeTestFiles(t, dir, map[string]string{"deps.lock": "b\n", "config.yml": "version: 2\n"})
+	if _, err := CommitFiles(repo, paths, "Update files", testCommitOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, diffErr := DiffCommits(repo, from, "HEAD")
+	if diffErr != nil {
+		t.Fatal(diffErr)
+	}
+
+	if !strings.Contains(diff, "+version: 2") {
+		t.Fatalf("Expected the content diff of config.yml, got:\n%s", diff)
+	}
+	if !strings.Contains(diff, "Binary files a/deps.lock and b/deps.lock differ") || strings.Contains(diff, "+b") {
+		t.Fatalf("Expected deps.lock to be summarized as a binary file, got:\n%s", diff)
+	}
+}
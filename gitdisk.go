@@ -3,17 +3,26 @@ package git
 import (
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"path"
+	"path/filepath"
+	"syscall"
+	"time"
 
+	"github.com/go-git/go-billy/v5/osfs"
 	gogit "github.com/go-git/go-git/v5"
+	gogitconf "github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
-	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/filesystem"
 )
 
-func cloneRepo(dir string, url string, ref string, pk *ssh.PublicKeys) (*GitRepository, error) {
-	repo, cloneErr := gogit.PlainClone(dir, false, &gogit.CloneOptions{
-		Auth:              pk,
+func cloneRepo(dir string, url string, ref string, auth transport.AuthMethod, bare bool) (*GitRepository, error) {
+	repo, cloneErr := gogit.PlainClone(dir, bare, &gogit.CloneOptions{
+		Auth:              auth,
 		RemoteName:        "origin",
 		URL:               url,
 		ReferenceName:     plumbing.NewBranchReferenceName(ref),
@@ -24,26 +33,26 @@ func cloneRepo(dir string, url string, ref string, pk *ssh.PublicKeys) (*GitRepo
 		Tags:              gogit.NoTags,
 	})
 	if cloneErr != nil {
-		return &GitRepository{repo}, errors.New(fmt.Sprintf("Error cloning in directory \"%s\": %s", dir, cloneErr.Error()))
+		return &GitRepository{Repo: repo}, fmt.Errorf("Error cloning in directory \"%s\": %w", dir, cloneErr)
 	}
 
-	fmt.Println(fmt.Sprintf("Cloned branch \"%s\" of repo \"%s\"", ref, url))
-	return &GitRepository{repo}, nil
+	logger.Printf("Cloned branch \"%s\" of repo \"%s\"", ref, url)
+	return &GitRepository{Repo: repo}, nil
 }
 
-func pullRepo(dir string, url string, ref string, pk *ssh.PublicKeys) (*GitRepository, bool, error) {
+func pullRepo(dir string, url string, ref string, auth transport.AuthMethod) (*GitRepository, bool, error) {
 	repo, gitErr := gogit.PlainOpen(dir)
 	if gitErr != nil {
-		return &GitRepository{repo}, true, errors.New(fmt.Sprintf("Error accessing repo in directory \"%s\": %s", dir, gitErr.Error()))
+		return &GitRepository{Repo: repo}, true, fmt.Errorf("Error accessing repo in directory \"%s\": %w", dir, gitErr)
 	}
 
 	worktree, worktreeErr := repo.Worktree()
 	if worktreeErr != nil {
-		return &GitRepository{repo}, true, errors.New(fmt.Sprintf("Error accessing worktree in directory \"%s\": %s", dir, worktreeErr.Error()))
+		return &GitRepository{Repo: repo}, true, fmt.Errorf("Error accessing worktree in directory \"%s\": %w", dir, worktreeErr)
 	}
 
 	pullErr := worktree.Pull(&gogit.PullOptions{
-		Auth:              pk,
+		Auth:              auth,
 		RemoteName:        "origin",
 		ReferenceName:     plumbing.NewBranchReferenceName(ref),
 		SingleBranch:      true,
@@ -53,36 +62,248 @@ func pullRepo(dir string, url string, ref string, pk *ssh.PublicKeys) (*GitRepos
 	})
 	if pullErr != nil && pullErr.Error() != gogit.NoErrAlreadyUpToDate.Error() {
 		fastForwardProblems := pullErr.Error() == gogit.ErrNonFastForwardUpdate.Error()
-		return &GitRepository{repo}, fastForwardProblems, errors.New(fmt.Sprintf("Error pulling latest changes in directory \"%s\": %s", dir, pullErr.Error()))
+		return &GitRepository{Repo: repo}, fastForwardProblems, fmt.Errorf("Error pulling latest changes in directory \"%s\": %w", dir, pullErr)
 	}
 	
 	if pullErr != nil && pullErr.Error() == gogit.NoErrAlreadyUpToDate.Error() {
-		fmt.Println(fmt.Sprintf("Branch \"%s\" of repo \"%s\" is up-to-date", ref, url))
+		logger.Printf("Branch \"%s\" of repo \"%s\" is up-to-date", ref, url)
 	} else {
 		head, headErr := repo.Head()
 		if headErr != nil {
-			return &GitRepository{repo}, true, errors.New(fmt.Sprintf("Error accessing top commit in directory \"%s\": %s", dir, headErr.Error()))
+			return &GitRepository{Repo: repo}, true, fmt.Errorf("Error accessing top commit in directory \"%s\": %w", dir, headErr)
 		}
-		fmt.Println(fmt.Sprintf("Branch \"%s\" of repo \"%s\" was updated to commit %s", ref, url, head.Hash()))
+		logger.Printf("Branch \"%s\" of repo \"%s\" was updated to commit %s", ref, url, head.Hash())
 	}
 
-	return &GitRepository{repo}, false, nil
+	return &GitRepository{Repo: repo}, false, nil
+}
+
+func fetchBareRepo(dir string, url string, ref string, auth transport.AuthMethod) (*GitRepository, error) {
+	repo, gitErr := gogit.PlainOpen(dir)
+	if gitErr != nil {
+		return &GitRepository{Repo: repo}, fmt.Errorf("Error accessing repo in directory \"%s\": %w", dir, gitErr)
+	}
+
+	branchRef := plumbing.NewBranchReferenceName(ref)
+	fetchErr := repo.Fetch(&gogit.FetchOptions{
+		Auth:       auth,
+		RemoteName: "origin",
+		RefSpecs:   []gogitconf.RefSpec{gogitconf.RefSpec(fmt.Sprintf("+%s:%s", branchRef, branchRef))},
+		Tags:       gogit.NoTags,
+		Progress:   nil,
+	})
+	if fetchErr != nil {
+		if fetchErr.Error() == gogit.NoErrAlreadyUpToDate.Error() {
+			logger.Printf("Branch \"%s\" of repo \"%s\" is up-to-date", ref, url)
+			return &GitRepository{Repo: repo}, nil
+		}
+
+		return &GitRepository{Repo: repo}, fmt.Errorf("Error fetching latest changes in directory \"%s\": %w", dir, fetchErr)
+	}
+
+	branch, branchErr := repo.Reference(branchRef, true)
+	if branchErr != nil {
+		return &GitRepository{Repo: repo}, fmt.Errorf("Error accessing top commit in directory \"%s\": %w", dir, branchErr)
+	}
+	logger.Printf("Branch \"%s\" of repo \"%s\" was updated to commit %s", ref, url, branch.Hash())
+
+	return &GitRepository{Repo: repo}, nil
 }
 
 /*
 Clone or pull the given reference of a given repo at a given path on the filesystem.
 If the repo was previously cloned at the path, a pull will be done, else a clone.
 */
-func SyncGitRepo(dir string, url string, ref string, sshCred *SshCredentials) (*GitRepository, bool, error) {
+func SyncGitRepo(dir string, url string, ref string, creds Credentials) (*GitRepository, bool, error) {
 	_, err := os.Stat(path.Join(dir, ".git"))
 	if err != nil {
 		if !os.IsNotExist(err) {
-			return nil, false, errors.New(fmt.Sprintf("Error accessing repo directory's .git sub-directory: %s", err.Error()))
+			return nil, false, fmt.Errorf("Error accessing repo directory's .git sub-directory: %w", err)
 		}
 
-		repo, cloneErr := cloneRepo(dir, url, ref, sshCred.Keys)
+		repo, cloneErr := cloneRepo(dir, url, ref, creds.AuthMethod(), false)
 		return repo, false, cloneErr
 	}
 
-	return pullRepo(dir, url, ref, sshCred.Keys)
-}
\ No newline at end of file
+	return pullRepo(dir, url, ref, creds.AuthMethod())
+}
+
+/*
+Optional parameters to pass to the SyncGitRepoWithOptions command
+*/
+type SyncOptions struct {
+	//If true, the repo is cloned without a worktree, directly in the given path, and subsequent syncs fetch the reference instead of pulling it.
+	//Operations reading the history (ex: verifying commits) work on bare repos, but operations on the worktree (ex: committing files) return an error
+	Bare               bool
+	//Optional operation log to attach to the repo. The clone, pull or fetch performed by the sync is recorded in it
+	OperationLog       *OperationLog
+	//Number of times to retry the clone if it fails due to a transient network error (ex: a connection that was reset, refused or timed out).
+	//Other errors, like authentication failures, host key mismatches, hosts that cannot be resolved and missing repositories or references, are not retried.
+	//The objects received by a failed clone are discarded by go-git, so each retry starts over from a clean directory
+	CloneRetries       int
+	//Interval to wait before each clone retry
+	CloneRetryInterval time.Duration
+}
+
+//Only errors known to be transient are retried, so that authentication failures and host key mismatches fail right away
+func isRetryableCloneError(err error) bool {
+	//A host that doesn't resolve (ex: a typo in the url) won't resolve on a retry either
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return false
+	}
+
+	retryable := []error{
+		io.ErrUnexpectedEOF,
+		syscall.ECONNRESET,
+		syscall.ECONNREFUSED,
+		syscall.ECONNABORTED,
+		syscall.ETIMEDOUT,
+		syscall.EPIPE,
+	}
+	for _, retryableErr := range retryable {
+		if errors.Is(err, retryableErr) {
+			return true
+		}
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func cloneRepoWithRetries(dir string, url string, ref string, auth transport.AuthMethod, bare bool, opts SyncOptions) (*GitRepository, error) {
+	repo, cloneErr := cloneRepo(dir, url, ref, auth, bare)
+	for attempt := 1; cloneErr != nil && attempt <= opts.CloneRetries && isRetryableCloneError(cloneErr); attempt++ {
+		logger.Printf("Clone of branch \"%s\" of repo \"%s\" failed, retrying (attempt %d of %d): %s", ref, url, attempt, opts.CloneRetries, cloneErr.Error())
+		time.Sleep(opts.CloneRetryInterval)
+		repo, cloneErr = cloneRepo(dir, url, ref, auth, bare)
+	}
+
+	return repo, cloneErr
+}
+
+/*
+Same as SyncGitRepo, but with additional options.
+*/
+func SyncGitRepoWithOptions(dir string, url string, ref string, creds Credentials, opts SyncOptions) (*GitRepository, bool, error) {
+	kind := PullOperation
+	markerPath := path.Join(dir, ".git")
+	if opts.Bare {
+		kind = FetchOperation
+		markerPath = path.Join(dir, "HEAD")
+	}
+
+	_, err := os.Stat(markerPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, false, fmt.Errorf("Error accessing repo directory's %s: %w", path.Base(markerPath), err)
+	}
+
+	var repo *GitRepository
+	var fastForwardProblems bool
+	var syncErr error
+	if err != nil {
+		kind = CloneOperation
+		repo, syncErr = cloneRepoWithRetries(dir, url, ref, creds.AuthMethod(), opts.Bare, opts)
+	} else if opts.Bare {
+		repo, syncErr = fetchBareRepo(dir, url, ref, creds.AuthMethod())
+	} else {
+		repo, fastForwardProblems, syncErr = pullRepo(dir, url, ref, creds.AuthMethod())
+	}
+
+	if syncErr == nil && opts.OperationLog != nil {
+		repo.AttachOperationLog(opts.OperationLog)
+		repo.recordOperation(kind, fmt.Sprintf("Synced branch \"%s\" of repo \"%s\" in directory \"%s\"", ref, url, dir))
+	}
+
+	return repo, fastForwardProblems, syncErr
+}
+
+/*
+Opens the git repository at the given path on the filesystem without performing any remote operation.
+This is useful to run read-only operations on a repository that was previously synced.
+*/
+func OpenRepo(dir string) (*GitRepository, error) {
+	repo, openErr := gogit.PlainOpen(dir)
+	if openErr != nil {
+		return nil, fmt.Errorf("Error opening repo in directory \"%s\": %w", dir, openErr)
+	}
+
+	return &GitRepository{Repo: repo}, nil
+}
+
+/*
+Opens the git repository whose git directory and worktree are at separate paths on the filesystem (ex: when the git directory lives on a different mount than the worktree).
+Like OpenRepo, no remote operation is performed.
+*/
+func OpenRepoWithGitDir(workTree string, gitDir string) (*GitRepository, error) {
+	storer := filesystem.NewStorage(osfs.New(gitDir), cache.NewObjectLRUDefault())
+	repo, openErr := gogit.Open(storer, osfs.New(workTree))
+	if openErr != nil {
+		return nil, fmt.Errorf("Error opening repo with git directory \"%s\" and worktree \"%s\": %w", gitDir, workTree, openErr)
+	}
+
+	return &GitRepository{Repo: repo}, nil
+}
+
+/*
+Initializes an empty git repository at the given path on the filesystem, with HEAD pointing to the given initial branch so that the first commit lands on it.
+If the initial branch is empty, it defaults to "main".
+*/
+func InitRepo(dir string, defaultBranch string) (*GitRepository, error) {
+	if defaultBranch == "" {
+		defaultBranch = "main"
+	}
+
+	repo, initErr := gogit.PlainInit(dir, false)
+	if initErr != nil {
+		return nil, fmt.Errorf("Error initializing repo in directory \"%s\": %w", dir, initErr)
+	}
+
+	headRef := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName(defaultBranch))
+	setErr := repo.Storer.SetReference(headRef)
+	if setErr != nil {
+		return nil, fmt.Errorf("Error setting initial branch of repo in directory \"%s\" to \"%s\": %w", dir, defaultBranch, setErr)
+	}
+
+	logger.Printf("Initialized repo in directory \"%s\" on branch \"%s\"", dir, defaultBranch)
+	return &GitRepository{Repo: repo}, nil
+}
+
+/*
+Error returned by FindRepoRoot when neither the given directory nor any of its parents contain a .git entry.
+*/
+type RepoRootNotFoundError struct {
+	StartDir string
+}
+
+func (e *RepoRootNotFoundError) Error() string {
+	return fmt.Sprintf("No git repository found in directory \"%s\" or any of its parents", e.StartDir)
+}
+
+/*
+Returns the root directory of the git repository containing the given directory, like "git rev-parse --show-toplevel" does.
+Parent directories are walked up until one containing a .git entry is found. If none is, a *RepoRootNotFoundError is returned.
+*/
+func FindRepoRoot(startDir string) (string, error) {
+	dir, absErr := filepath.Abs(startDir)
+	if absErr != nil {
+		return "", fmt.Errorf("Error resolving absolute path of directory \"%s\": %w", startDir, absErr)
+	}
+
+	for {
+		_, statErr := os.Stat(filepath.Join(dir, ".git"))
+		if statErr == nil {
+			return dir, nil
+		}
+
+		if !os.IsNotExist(statErr) {
+			return "", fmt.Errorf("Error accessing .git entry of directory \"%s\": %w", dir, statErr)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", &RepoRootNotFoundError{StartDir: startDir}
+		}
+		dir = parent
+	}
+}
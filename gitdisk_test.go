@@ -0,0 +1,190 @@
+package git
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestIsRetryableCloneError(t *testing.T) {
+	cases := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, true},
+		{"connection reset", fmt.Errorf("Error cloning: %w", syscall.ECONNRESET), true},
+		{"truncated packfile", fmt.Errorf("Error cloning: %w", io.ErrUnexpectedEOF), true},
+		{"ssh authentication", errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none publickey]"), false},
+		{"host key mismatch", fmt.Errorf("Error cloning: %w", &knownhosts.KeyError{Want: []knownhosts.KnownKey{{}}}), false},
+		{"http authentication", fmt.Errorf("Error cloning: %w", transport.ErrAuthenticationRequired), false},
+		{"missing repository", fmt.Errorf("Error cloning: %w", transport.ErrRepositoryNotFound), false},
+		{"unknown host", &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "gitlab.exmaple.com", IsNotFound: true}}, false},
+		{"dns timeout", &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "i/o timeout", Name: "gitlab.example.com", IsTimeout: true}}, true},
+	}
+
+	for _, c := range cases {
+		if got := isRetryableCloneError(c.err); got != c.retryable {
+			t.Errorf("%s: expected retryable to be %t, got %t", c.name, c.retryable, got)
+		}
+	}
+}
+
+//Serves the git repositories of the filesystem over ssh on a random local port, running the git binary for each upload-pack or receive-pack command
+func newTestSshServer(t *testing.T, clientKey gossh.PublicKey) (gossh.Signer, int) {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary is required to serve repositories over ssh")
+	}
+
+	_, hostPrivate, hostErr := ed25519.GenerateKey(rand.Reader)
+	if hostErr != nil {
+		t.Fatal(hostErr)
+	}
+	hostKey, hostKeyErr := gossh.NewSignerFromKey(hostPrivate)
+	if hostKeyErr != nil {
+		t.Fatal(hostKeyErr)
+	}
+
+	config := &gossh.ServerConfig{
+		PublicKeyCallback: func(conn gossh.ConnMetadata, key gossh.PublicKey) (*gossh.Permissions, error) {
+			if !bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, errors.New("unknown client key")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+	if listenErr != nil {
+		t.Fatal(listenErr)
+	}
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			go serveTestSshConn(conn, config)
+		}
+	}()
+
+	return hostKey, listener.Addr().(*net.TCPAddr).Port
+}
+
+func serveTestSshConn(conn net.Conn, config *gossh.ServerConfig) {
+	defer conn.Close()
+
+	_, channels, requests, handshakeErr := gossh.NewServerConn(conn, config)
+	if handshakeErr != nil {
+		return
+	}
+	go gossh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(gossh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+
+		channel, channelRequests, acceptErr := newChannel.Accept()
+		if acceptErr != nil {
+			return
+		}
+
+		go func() {
+			defer channel.Close()
+			for req := range channelRequests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				req.Reply(true, nil)
+
+				//The payload is the length-prefixed command, ex: git-upload-pack '/path/to/repo'
+				fields := strings.SplitN(string(req.Payload[4:]), " ", 2)
+				cmd := exec.Command("git", strings.TrimPrefix(fields[0], "git-"), strings.Trim(fields[1], "'"))
+				cmd.Stdin = channel
+				cmd.Stdout = channel
+				cmd.Stderr = channel.Stderr()
+
+				status := uint32(0)
+				if err := cmd.Run(); err != nil {
+					status = 1
+				}
+				channel.SendRequest("exit-status", false, gossh.Marshal(struct{ Status uint32 }{status}))
+				return
+			}
+		}()
+	}
+}
+
+func newTestSshClientKey(t *testing.T) ([]byte, gossh.PublicKey) {
+	t.Helper()
+
+	private, keyErr := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if keyErr != nil {
+		t.Fatal(keyErr)
+	}
+	der, derErr := x509.MarshalECPrivateKey(private)
+	if derErr != nil {
+		t.Fatal(derErr)
+	}
+	public, publicErr := gossh.NewPublicKey(&private.PublicKey)
+	if publicErr != nil {
+		t.Fatal(publicErr)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), public
+}
+
+func TestSyncGitRepoOverCustomSshPort(t *testing.T) {
+	remote := newTestRemote(t, map[string]string{"a.txt": "a\n"})
+	clientKey, clientPublicKey := newTestSshClientKey(t)
+	hostKey, port := newTestSshServer(t, clientPublicKey)
+	url := fmt.Sprintf("ssh://git@127.0.0.1:%d%s", port, remote)
+
+	//Without the port, the host key entry doesn't match the server
+	portlessCreds, portlessErr := GetSshCredentialsFromBytes(clientKey, []byte(KnownHostsEntry("127.0.0.1", 22, hostKey.PublicKey())+"\n"), "git")
+	if portlessErr != nil {
+		t.Fatal(portlessErr)
+	}
+	_, _, rejectedErr := SyncGitRepo(filepath.Join(t.TempDir(), "clone"), url, "main", portlessCreds)
+	if rejectedErr == nil || !strings.Contains(rejectedErr.Error(), fmt.Sprintf("[host]:%d", port)) {
+		t.Fatalf("Expected host key to be rejected with a hint about the port, got: %v", rejectedErr)
+	}
+
+	creds, credsErr := GetSshCredentialsFromBytes(clientKey, []byte(KnownHostsEntry("127.0.0.1", port, hostKey.PublicKey())+"\n"), "git")
+	if credsErr != nil {
+		t.Fatal(credsErr)
+	}
+	repo, _, syncErr := SyncGitRepo(filepath.Join(t.TempDir(), "clone"), url, "main", creds)
+	if syncErr != nil {
+		t.Fatal(syncErr)
+	}
+
+	assertTestFiles(t, getTestTreeFiles(t, getTestHeadCommit(t, repo)), map[string]string{"a.txt": "a\n"})
+}
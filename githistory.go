@@ -0,0 +1,568 @@
+package git
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/object/commitgraph"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+func getHeadCommit(repo *GitRepository) (*object.Commit, error) {
+	head, headErr := repo.Repo.Head()
+	if headErr != nil {
+		return nil, fmt.Errorf("Error accessing repo head: %w", headErr)
+	}
+
+	commit, commitErr := repo.Repo.CommitObject(head.Hash())
+	if commitErr != nil {
+		return nil, fmt.Errorf("Error accessing repo top commit: %w", commitErr)
+	}
+
+	return commit, nil
+}
+
+func resolveCommit(repo *GitRepository, ref string) (*object.Commit, error) {
+	hash, resolveErr := repo.Repo.ResolveRevision(plumbing.Revision(ref))
+	if resolveErr != nil {
+		return nil, fmt.Errorf("Error resolving reference \"%s\": %w", ref, resolveErr)
+	}
+
+	commit, commitErr := repo.Repo.CommitObject(*hash)
+	if commitErr != nil {
+		return nil, fmt.Errorf("Error accessing commit \"%s\" referenced by \"%s\": %w", hash, ref, commitErr)
+	}
+
+	return commit, nil
+}
+
+const shortHashMinLength = 7
+
+func commonPrefixLength(a string, b string) int {
+	length := 0
+	for length < len(a) && length < len(b) && a[length] == b[length] {
+		length++
+	}
+
+	return length
+}
+
+//Storages on the filesystem can look up the objects whose hashes start with a prefix in the loose objects directory and pack indexes,
+//without reading the objects. Other storages (ex: in memory) have their objects iterated over
+func getHashesWithPrefix(repo *GitRepository, prefix []byte) ([]plumbing.Hash, error) {
+	type prefixStorer interface {
+		HashesWithPrefix(prefix []byte) ([]plumbing.Hash, error)
+	}
+	if prefixStore, ok := repo.Repo.Storer.(prefixStorer); ok {
+		hashes, hashesErr := prefixStore.HashesWithPrefix(prefix)
+		if hashesErr != nil {
+			return nil, fmt.Errorf("Error looking up repo objects by prefix: %w", hashesErr)
+		}
+
+		return hashes, nil
+	}
+
+	objects, objectsErr := repo.Repo.Storer.IterEncodedObjects(plumbing.AnyObject)
+	if objectsErr != nil {
+		return nil, fmt.Errorf("Error iterating over repo objects: %w", objectsErr)
+	}
+	defer objects.Close()
+
+	hashes := []plumbing.Hash{}
+	iterErr := objects.ForEach(func(obj plumbing.EncodedObject) error {
+		hash := obj.Hash()
+		if bytes.HasPrefix(hash[:], prefix) {
+			hashes = append(hashes, hash)
+		}
+
+		return nil
+	})
+	if iterErr != nil {
+		return nil, fmt.Errorf("Error iterating over repo objects: %w", iterErr)
+	}
+
+	return hashes, nil
+}
+
+/*
+Returns the shortest abbreviation of the given full hash that is unambiguous in the git repository, similarly to "git rev-parse --short".
+Like git, abbreviations will never be shorter than 7 characters.
+*/
+func ShortHash(repo *GitRepository, hash string) (string, error) {
+	hash = strings.ToLower(hash)
+	fullHash := plumbing.NewHash(hash)
+	if len(hash) != len(fullHash.String()) || fullHash.String() != hash {
+		return "", fmt.Errorf("\"%s\" is not a valid full hash", hash)
+	}
+
+	hasErr := repo.Repo.Storer.HasEncodedObject(fullHash)
+	if hasErr != nil {
+		return "", fmt.Errorf("Error accessing object \"%s\": %w", hash, hasErr)
+	}
+
+	//Only the objects sharing the first bytes of the minimum abbreviation can make it ambiguous
+	candidates, candidatesErr := getHashesWithPrefix(repo, fullHash[:shortHashMinLength/2])
+	if candidatesErr != nil {
+		return "", candidatesErr
+	}
+
+	longestCommonPrefix := 0
+	for _, candidate := range candidates {
+		if candidate == fullHash {
+			continue
+		}
+
+		prefixLength := commonPrefixLength(hash, candidate.String())
+		if prefixLength > longestCommonPrefix {
+			longestCommonPrefix = prefixLength
+		}
+	}
+
+	length := longestCommonPrefix + 1
+	if length < shortHashMinLength {
+		length = shortHashMinLength
+	}
+	if length > len(hash) {
+		length = len(hash)
+	}
+
+	return hash[:length], nil
+}
+
+/*
+Returns all the commits reachable from the top commit of the git repository that were authored at or after the given time, newest commit first.
+The author date is used rather than the commit date, so commits that were rebased or cherry-picked after the given time but authored before it are excluded.
+*/
+func GetCommitsSince(repo *GitRepository, since time.Time) ([]*object.Commit, error) {
+	head, headErr := repo.Repo.Head()
+	if headErr != nil {
+		return nil, fmt.Errorf("Error accessing repo head: %w", headErr)
+	}
+
+	//The Since option of go-git filters on the commit date, so the author date is filtered here instead
+	commitsIter, logErr := repo.Repo.Log(&gogit.LogOptions{
+		From:  head.Hash(),
+		Order: gogit.LogOrderCommitterTime,
+	})
+	if logErr != nil {
+		return nil, fmt.Errorf("Error accessing repo history: %w", logErr)
+	}
+	defer commitsIter.Close()
+
+	commits := []*object.Commit{}
+	iterErr := commitsIter.ForEach(func(commit *object.Commit) error {
+		if !commit.Author.When.Before(since) {
+			commits = append(commits, commit)
+		}
+		return nil
+	})
+	if iterErr != nil {
+		return nil, fmt.Errorf("Error iterating over repo history: %w", iterErr)
+	}
+
+	return commits, nil
+}
+
+/*
+Function signature meant to be passed as an argument to the WalkHistory function.
+It is called on each visited commit and should return true to stop the walk early.
+*/
+type HistoryVisitor func(commit *object.Commit) (bool, error)
+
+/*
+Walks the history of the git repository starting from its top commit, calling the visitor on each commit until it returns true.
+If the visitor returns an error, the walk is stopped and the error is returned.
+*/
+func WalkHistory(repo *GitRepository, visitor HistoryVisitor) error {
+	head, headErr := repo.Repo.Head()
+	if headErr != nil {
+		return fmt.Errorf("Error accessing repo head: %w", headErr)
+	}
+
+	commitsIter, logErr := repo.Repo.Log(&gogit.LogOptions{
+		From: head.Hash(),
+	})
+	if logErr != nil {
+		return fmt.Errorf("Error accessing repo history: %w", logErr)
+	}
+	defer commitsIter.Close()
+
+	var visitorErr error
+	iterErr := commitsIter.ForEach(func(commit *object.Commit) error {
+		stop, err := visitor(commit)
+		if err != nil {
+			visitorErr = err
+			return storer.ErrStop
+		}
+
+		if stop {
+			return storer.ErrStop
+		}
+
+		return nil
+	})
+	if visitorErr != nil {
+		return visitorErr
+	}
+	if iterErr != nil {
+		return fmt.Errorf("Error iterating over repo history: %w", iterErr)
+	}
+
+	return nil
+}
+
+func walkCommitHashes(repo *GitRepository, from plumbing.Hash, visit func(hash plumbing.Hash) bool) error {
+	shallowCommits, shallowErr := repo.Repo.Storer.Shallow()
+	if shallowErr != nil {
+		return fmt.Errorf("Error accessing repo shallow commits: %w", shallowErr)
+	}
+
+	//The parents of the boundary commits of shallow clones are not available
+	boundaries := map[plumbing.Hash]bool{}
+	for _, shallowCommit := range shallowCommits {
+		boundaries[shallowCommit] = true
+	}
+
+	nodeIndex := commitgraph.NewObjectCommitNodeIndex(repo.Repo.Storer)
+	visited := map[plumbing.Hash]bool{from: true}
+	pending := []plumbing.Hash{from}
+
+	for len(pending) > 0 {
+		hash := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if !visit(hash) || boundaries[hash] {
+			continue
+		}
+
+		node, nodeErr := nodeIndex.Get(hash)
+		if nodeErr != nil {
+			return fmt.Errorf("Error accessing commit \"%s\": %w", hash, nodeErr)
+		}
+
+		for _, parentHash := range node.ParentHashes() {
+			if !visited[parentHash] {
+				visited[parentHash] = true
+				pending = append(pending, parentHash)
+			}
+		}
+	}
+
+	return nil
+}
+
+/*
+Returns the number of commits reachable from the top commit of the git repository, like "git rev-list --count HEAD".
+Only the commit graph is walked, the trees and files of the commits are not loaded.
+*/
+func CommitCount(repo *GitRepository) (int, error) {
+	head, headErr := repo.Repo.Head()
+	if headErr != nil {
+		return 0, fmt.Errorf("Error accessing repo head: %w", headErr)
+	}
+
+	count := 0
+	walkErr := walkCommitHashes(repo, head.Hash(), func(hash plumbing.Hash) bool {
+		count++
+		return true
+	})
+	if walkErr != nil {
+		return 0, walkErr
+	}
+
+	return count, nil
+}
+
+/*
+Returns the parent commits of the commit with the given hash: none for a root commit, one for a regular commit and several for a merge commit.
+*/
+func GetParents(repo *GitRepository, hash string) ([]*object.Commit, error) {
+	commit, commitErr := repo.Repo.CommitObject(plumbing.NewHash(hash))
+	if commitErr != nil {
+		return nil, fmt.Errorf("Error accessing commit \"%s\": %w", hash, commitErr)
+	}
+
+	parents := []*object.Commit{}
+	for idx := 0; idx < commit.NumParents(); idx++ {
+		parent, parentErr := commit.Parent(idx)
+		if parentErr != nil {
+			return nil, fmt.Errorf("Error accessing parent of commit \"%s\": %w", hash, parentErr)
+		}
+
+		parents = append(parents, parent)
+	}
+
+	return parents, nil
+}
+
+/*
+Returns the number of commits reachable from the "to" reference but not from the "from" reference, like "git rev-list --count from..to".
+References can be branches, tags, commit hashes, etc. Only the commit graph is walked.
+*/
+func CommitCountBetween(repo *GitRepository, fromRef string, toRef string) (int, error) {
+	hashes, hashesErr := commitHashesBetween(repo, fromRef, toRef)
+	if hashesErr != nil {
+		return 0, hashesErr
+	}
+
+	return len(hashes), nil
+}
+
+func commitHashesBetween(repo *GitRepository, fromRef string, toRef string) ([]plumbing.Hash, error) {
+	fromCommit, fromErr := resolveCommit(repo, fromRef)
+	if fromErr != nil {
+		return nil, fromErr
+	}
+
+	toCommit, toErr := resolveCommit(repo, toRef)
+	if toErr != nil {
+		return nil, toErr
+	}
+
+	excluded := map[plumbing.Hash]bool{}
+	walkErr := walkCommitHashes(repo, fromCommit.Hash, func(hash plumbing.Hash) bool {
+		excluded[hash] = true
+		return true
+	})
+	if walkErr != nil {
+		return nil, walkErr
+	}
+
+	hashes := []plumbing.Hash{}
+	walkErr = walkCommitHashes(repo, toCommit.Hash, func(hash plumbing.Hash) bool {
+		if excluded[hash] {
+			return false
+		}
+
+		hashes = append(hashes, hash)
+		return true
+	})
+	if walkErr != nil {
+		return nil, walkErr
+	}
+
+	return hashes, nil
+}
+
+/*
+Order in which the GetCommits function returns commits
+*/
+type CommitOrder int
+
+const (
+	//Newest commit date first
+	CommitterDateOrder CommitOrder = iota
+	//Newest author date first
+	AuthorDateOrder
+	//Children before their parents, with ties broken by newest commit date first, like "git log --date-order".
+	//Unlike date orders, this guarantees that a merge is listed before the commits it merged even if some have skewed dates
+	TopologicalOrder
+)
+
+type commitIndexHeap []int
+
+func (h commitIndexHeap) Len() int {
+	return len(h)
+}
+
+func (h commitIndexHeap) Less(i int, j int) bool {
+	return h[i] < h[j]
+}
+
+func (h commitIndexHeap) Swap(i int, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *commitIndexHeap) Push(x interface{}) {
+	*h = append(*h, x.(int))
+}
+
+func (h *commitIndexHeap) Pop() interface{} {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+func sortTopologically(commits []*object.Commit) []*object.Commit {
+	positions := map[plumbing.Hash]int{}
+	for idx, commit := range commits {
+		positions[commit.Hash] = idx
+	}
+
+	childCounts := make([]int, len(commits))
+	for _, commit := range commits {
+		for _, parentHash := range commit.ParentHashes {
+			if parentIdx, ok := positions[parentHash]; ok {
+				childCounts[parentIdx]++
+			}
+		}
+	}
+
+	//Commits are in commit date order, so always picking the ready commit with the lowest position breaks ties by date
+	ready := &commitIndexHeap{}
+	for idx := range commits {
+		if childCounts[idx] == 0 {
+			heap.Push(ready, idx)
+		}
+	}
+
+	sorted := make([]*object.Commit, 0, len(commits))
+	for ready.Len() > 0 {
+		commit := commits[heap.Pop(ready).(int)]
+		sorted = append(sorted, commit)
+
+		for _, parentHash := range commit.ParentHashes {
+			if parentIdx, ok := positions[parentHash]; ok {
+				childCounts[parentIdx]--
+				if childCounts[parentIdx] == 0 {
+					heap.Push(ready, parentIdx)
+				}
+			}
+		}
+	}
+
+	return sorted
+}
+
+/*
+Returns the commits reachable from the top commit of the git repository in the given order.
+If max is greater than 0, at most max commits are returned. Note that for the author date and topological orders, the whole history is read before it is truncated.
+*/
+func GetCommits(repo *GitRepository, max int, order CommitOrder) ([]*object.Commit, error) {
+	head, headErr := repo.Repo.Head()
+	if headErr != nil {
+		return nil, fmt.Errorf("Error accessing repo head: %w", headErr)
+	}
+
+	commitsIter, logErr := repo.Repo.Log(&gogit.LogOptions{
+		From:  head.Hash(),
+		Order: gogit.LogOrderCommitterTime,
+	})
+	if logErr != nil {
+		return nil, fmt.Errorf("Error accessing repo history: %w", logErr)
+	}
+	defer commitsIter.Close()
+
+	commits := []*object.Commit{}
+	iterErr := commitsIter.ForEach(func(commit *object.Commit) error {
+		commits = append(commits, commit)
+		if order == CommitterDateOrder && max > 0 && len(commits) >= max {
+			return storer.ErrStop
+		}
+		return nil
+	})
+	if iterErr != nil {
+		return nil, fmt.Errorf("Error iterating over repo history: %w", iterErr)
+	}
+
+	switch order {
+	case CommitterDateOrder:
+	case AuthorDateOrder:
+		sort.SliceStable(commits, func(i, j int) bool {
+			return commits[i].Author.When.After(commits[j].Author.When)
+		})
+	case TopologicalOrder:
+		commits = sortTopologically(commits)
+	default:
+		return nil, fmt.Errorf("Unsupported commit order %d", order)
+	}
+
+	if max > 0 && len(commits) > max {
+		commits = commits[:max]
+	}
+
+	return commits, nil
+}
+
+/*
+Returns the hash of the tree of the top commit of the git repository.
+Unlike the commit hash, it only depends on the content of the commit, not on its metadata (author, date, message, etc).
+*/
+func GetTreeHash(repo *GitRepository) (string, error) {
+	commit, commitErr := getHeadCommit(repo)
+	if commitErr != nil {
+		return "", commitErr
+	}
+
+	return commit.TreeHash.String(), nil
+}
+
+/*
+Returns the hash of the tree of the commit the given reference (branch, tag, commit hash, etc) points to.
+*/
+func GetTreeHashAt(repo *GitRepository, ref string) (string, error) {
+	commit, commitErr := resolveCommit(repo, ref)
+	if commitErr != nil {
+		return "", commitErr
+	}
+
+	return commit.TreeHash.String(), nil
+}
+
+/*
+Returns the time elapsed since the top commit of the git repository was committed.
+The committer date is used rather than the author date, as it reflects when the commit actually landed on the branch (ex: after a rebase).
+*/
+func TopCommitAge(repo *GitRepository) (time.Duration, error) {
+	commit, commitErr := getHeadCommit(repo)
+	if commitErr != nil {
+		return 0, commitErr
+	}
+
+	return time.Since(commit.Committer.When), nil
+}
+
+/*
+Error returned when a commit referenced by its hash doesn't exist in the git repository (ex: it wasn't fetched in a shallow clone).
+*/
+type CommitNotFoundError struct {
+	Hash string
+}
+
+func (e *CommitNotFoundError) Error() string {
+	return fmt.Sprintf("Commit \"%s\" was not found in the repo", e.Hash)
+}
+
+func getCommitByHash(repo *GitRepository, hash string) (*object.Commit, error) {
+	commit, commitErr := repo.Repo.CommitObject(plumbing.NewHash(hash))
+	if commitErr != nil {
+		if commitErr == plumbing.ErrObjectNotFound {
+			return nil, &CommitNotFoundError{Hash: hash}
+		}
+		return nil, fmt.Errorf("Error accessing commit \"%s\": %w", hash, commitErr)
+	}
+
+	return commit, nil
+}
+
+/*
+Returns whether the commit with the ancestor hash is an ancestor of the commit with the descendant hash, like "git merge-base --is-ancestor".
+A commit is considered its own ancestor. Unrelated commits are not an error and simply return false.
+If either commit doesn't exist in the repository, a *CommitNotFoundError is returned.
+*/
+func IsAncestor(repo *GitRepository, ancestorHash string, descendantHash string) (bool, error) {
+	ancestor, ancestorErr := getCommitByHash(repo, ancestorHash)
+	if ancestorErr != nil {
+		return false, ancestorErr
+	}
+
+	descendant, descendantErr := getCommitByHash(repo, descendantHash)
+	if descendantErr != nil {
+		return false, descendantErr
+	}
+
+	isAncestor, checkErr := ancestor.IsAncestor(descendant)
+	if checkErr != nil {
+		return false, fmt.Errorf("Error checking if commit \"%s\" is an ancestor of commit \"%s\": %w", ancestorHash, descendantHash, checkErr)
+	}
+
+	return isAncestor, nil
+}
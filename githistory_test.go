@@ -0,0 +1,182 @@
+package git
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func TestGetCommitsSinceFiltersOnAuthorDate(t *testing.T) {
+	repo, _ := newTestRepo(t, map[string]string{"a.txt": "a\n"})
+	head := getTestHeadCommit(t, repo)
+	since := time.Now().Add(30 * 24 * time.Hour)
+
+	dates := []struct {
+		message   string
+		author    time.Time
+		committer time.Time
+	}{
+		{"Authored and committed before", since.Add(-48 * time.Hour), since.Add(-48 * time.Hour)},
+		{"Authored before and rebased after", since.Add(-24 * time.Hour), since.Add(time.Hour)},
+		{"Authored and committed after", since.Add(2 * time.Hour), since.Add(2 * time.Hour)},
+	}
+
+	parent := head.Hash
+	for _, date := range dates {
+		parent = storeTestObject(t, repo, &object.Commit{
+			Author:       object.Signature{Name: "Test Author", Email: "author@example.com", When: date.author},
+			Committer:    object.Signature{Name: "Test Author", Email: "author@example.com", When: date.committer},
+			Message:      date.message,
+			TreeHash:     head.TreeHash,
+			ParentHashes: []plumbing.Hash{parent},
+		})
+	}
+	if err := repo.Repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("main"), parent)); err != nil {
+		t.Fatal(err)
+	}
+
+	commits, commitsErr := GetCommitsSince(repo, since)
+	if commitsErr != nil {
+		t.Fatal(commitsErr)
+	}
+	if len(commits) != 1 || commits[0].Message != "Authored and committed after" {
+		messages := []string{}
+		for _, commit := range commits {
+			messages = append(messages, commit.Message)
+		}
+		t.Fatalf("Expected only the commit authored after the given time, got %v", messages)
+	}
+}
+
+func TestGetCommitsOrders(t *testing.T) {
+	dir := t.TempDir()
+	repo, initErr := InitRepo(dir, "main")
+	if initErr != nil {
+		t.Fatal(initErr)
+	}
+
+	base := time.Unix(1700000000, 0)
+	at := func(hours int) time.Time {
+		return base.Add(time.Duration(hours) * time.Hour)
+	}
+	tree := storeTestObject(t, repo, &object.Tree{})
+	addCommit := func(msg string, authored int, committed int, parents ...plumbing.Hash) plumbing.Hash {
+		return storeTestObject(t, repo, &object.Commit{
+			Author:       object.Signature{Name: "Test Author", Email: "author@example.com", When: at(authored)},
+			Committer:    object.Signature{Name: "Test Author", Email: "author@example.com", When: at(committed)},
+			Message:      msg,
+			TreeHash:     tree,
+			ParentHashes: parents,
+		})
+	}
+
+	//The root commit has a skewed commit date, later than the ones of its children
+	root := addCommit("root", 1, 7)
+	left := addCommit("left", 4, 2, root)
+	right := addCommit("right", 2, 5, root)
+	merge := addCommit("merge", 3, 3, left, right)
+	if err := moveHead(repo, merge); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		order    CommitOrder
+		max      int
+		expected []plumbing.Hash
+	}{
+		{CommitterDateOrder, 0, []plumbing.Hash{merge, right, root, left}},
+		{CommitterDateOrder, 2, []plumbing.Hash{merge, right}},
+		{AuthorDateOrder, 0, []plumbing.Hash{left, merge, right, root}},
+		{AuthorDateOrder, 2, []plumbing.Hash{left, merge}},
+		{TopologicalOrder, 0, []plumbing.Hash{merge, right, left, root}},
+		{TopologicalOrder, 3, []plumbing.Hash{merge, right, left}},
+		{TopologicalOrder, 10, []plumbing.Hash{merge, right, left, root}},
+	}
+
+	for _, test := range tests {
+		commits, commitsErr := GetCommits(repo, test.max, test.order)
+		if commitsErr != nil {
+			t.Fatal(commitsErr)
+		}
+
+		hashes := []plumbing.Hash{}
+		for _, commit := range commits {
+			hashes = append(hashes, commit.Hash)
+		}
+		if len(hashes) != len(test.expected) {
+			t.Fatalf("Expected commits %v in order %d with max %d, got %v", test.expected, test.order, test.max, hashes)
+		}
+		for idx := range hashes {
+			if hashes[idx] != test.expected[idx] {
+				t.Fatalf("Expected commits %v in order %d with max %d, got %v", test.expected, test.order, test.max, hashes)
+			}
+		}
+	}
+
+	if _, err := GetCommits(repo, 0, CommitOrder(42)); err == nil {
+		t.Fatal("Expected an error for an unsupported commit order")
+	}
+}
+
+//The hashes of these blobs share their first 8 characters: 59830d3595eede61... and 59830d35768b4e8c...
+var testAmbiguousBlobs = []string{"ambiguous 46438\n", "ambiguous 146689\n"}
+
+func assertTestShortHashes(t *testing.T, repo *GitRepository, expected map[string]string) {
+	t.Helper()
+
+	for hash, short := range expected {
+		got, shortErr := ShortHash(repo, hash)
+		if shortErr != nil {
+			t.Fatal(shortErr)
+		}
+		if got != short {
+			t.Fatalf("Expected short hash %s for %s, got %s", short, hash, got)
+		}
+	}
+}
+
+func TestShortHashAmbiguousPrefix(t *testing.T) {
+	repo, dir := newTestRepo(t, map[string]string{"a.txt": "a\n"})
+	first := storeTestBlob(t, repo, testAmbiguousBlobs[0])
+	second := storeTestBlob(t, repo, testAmbiguousBlobs[1])
+	head := getTestHeadCommit(t, repo).Hash.String()
+	expected := map[string]string{
+		first.String():  "59830d359",
+		second.String(): "59830d357",
+		head:            head[:7],
+	}
+
+	//Loose objects
+	assertTestShortHashes(t, repo, expected)
+
+	//Packed objects
+	repack := exec.Command("git", "repack", "-a", "-d", "-q")
+	repack.Dir = dir
+	if output, err := repack.CombinedOutput(); err != nil {
+		t.Fatalf("Error repacking repo: %v: %s", err, output)
+	}
+	packed, openErr := gogit.PlainOpen(dir)
+	if openErr != nil {
+		t.Fatal(openErr)
+	}
+	assertTestShortHashes(t, &GitRepository{Repo: packed}, expected)
+
+	//Objects in memory
+	mem, initErr := gogit.Init(memory.NewStorage(), memfs.New())
+	if initErr != nil {
+		t.Fatal(initErr)
+	}
+	memRepo := &GitRepository{Repo: mem}
+	storeTestBlob(t, memRepo, testAmbiguousBlobs[0])
+	storeTestBlob(t, memRepo, testAmbiguousBlobs[1])
+	assertTestShortHashes(t, memRepo, map[string]string{
+		first.String():  "59830d359",
+		second.String(): "59830d357",
+	})
+}
@@ -0,0 +1,129 @@
+package git
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+/*
+Identity of the author or committer of a commit, as serialized in CommitInfo
+*/
+type CommitIdentity struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+/*
+Trusted key that signed a commit, as serialized in CommitInfo
+*/
+type CommitSigner struct {
+	Fingerprint string   `json:"fingerprint"`
+	Identities  []string `json:"identities"`
+}
+
+/*
+Metadata of a commit with a stable json serialization, suitable for webhook payloads
+*/
+type CommitInfo struct {
+	Hash      string         `json:"hash"`
+	Parents   []string       `json:"parents"`
+	Author    CommitIdentity `json:"author"`
+	Committer CommitIdentity `json:"committer"`
+	Message   string         `json:"message"`
+	Signed    bool           `json:"signed"`
+	//Only set if the commit is signed by a key trusted by the verifier passed to GetCommitInfo
+	Signer    *CommitSigner  `json:"signer,omitempty"`
+}
+
+func toCommitIdentity(sig object.Signature) CommitIdentity {
+	return CommitIdentity{Name: sig.Name, Email: sig.Email, Date: sig.When}
+}
+
+/*
+Returns the metadata of the commit with the given hash.
+If a verifier is passed, the signature of the commit is verified with it and the signer is included if it is trusted.
+A commit that fails verification is not an error, it just has no signer.
+*/
+func GetCommitInfo(repo *GitRepository, hash string, verifier *Verifier) (*CommitInfo, error) {
+	commit, commitErr := repo.Repo.CommitObject(plumbing.NewHash(hash))
+	if commitErr != nil {
+		return nil, fmt.Errorf("Error accessing commit \"%s\": %w", hash, commitErr)
+	}
+
+	parents := []string{}
+	for _, parentHash := range commit.ParentHashes {
+		parents = append(parents, parentHash.String())
+	}
+
+	info := &CommitInfo{
+		Hash:      commit.Hash.String(),
+		Parents:   parents,
+		Author:    toCommitIdentity(commit.Author),
+		Committer: toCommitIdentity(commit.Committer),
+		Message:   commit.Message,
+		Signed:    commit.PGPSignature != "",
+	}
+
+	if verifier != nil && info.Signed {
+		entity, verifyErr := verifier.verifyCommit(commit)
+		if verifyErr == nil {
+			identities := []string{}
+			for name := range entity.Identities {
+				identities = append(identities, name)
+			}
+			sort.Strings(identities)
+
+			info.Signer = &CommitSigner{
+				Fingerprint: hex.EncodeToString(entity.PrimaryKey.Fingerprint),
+				Identities:  identities,
+			}
+		}
+	}
+
+	return info, nil
+}
+
+/*
+Optional parameters to pass to the TopCommitJSONWithOptions command
+*/
+type TopCommitJSONOptions struct {
+	//Optional verifier used to verify the signature of the commit. See GetCommitInfo
+	Verifier *Verifier
+}
+
+/*
+Returns the metadata of the top commit of the git repository serialized in json.
+The signature of the commit is not verified, so the signer is never set. Use TopCommitJSONWithOptions to verify it.
+*/
+func (repo *GitRepository) TopCommitJSON() ([]byte, error) {
+	return repo.TopCommitJSONWithOptions(TopCommitJSONOptions{})
+}
+
+/*
+Same as TopCommitJSON, but if a verifier is passed in the options, the signature of the commit is verified with it and the signer is included if it is trusted.
+*/
+func (repo *GitRepository) TopCommitJSONWithOptions(opts TopCommitJSONOptions) ([]byte, error) {
+	head, headErr := repo.Repo.Head()
+	if headErr != nil {
+		return nil, fmt.Errorf("Error accessing repo head: %w", headErr)
+	}
+
+	info, infoErr := GetCommitInfo(repo, head.Hash().String(), opts.Verifier)
+	if infoErr != nil {
+		return nil, infoErr
+	}
+
+	serialized, marshalErr := json.Marshal(info)
+	if marshalErr != nil {
+		return nil, fmt.Errorf("Error serializing top commit \"%s\" in json: %w", head.Hash(), marshalErr)
+	}
+
+	return serialized, nil
+}
@@ -0,0 +1,55 @@
+package git
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+func TestTopCommitJSON(t *testing.T) {
+	key := newTestSignatureKey(t, "Test Author", "author@example.com")
+	repo, dir := newTestRepo(t, map[string]string{"a.txt": "a\n"})
+	parent := getTestHeadCommit(t, repo)
+
+	opts := testCommitOptions
+	opts.SignatureKey = key
+	paths := writeTestFiles(t, dir, map[string]string{"b.txt": "b\n"})
+	if _, err := CommitFiles(repo, paths, "Add b.txt", opts); err != nil {
+		t.Fatal(err)
+	}
+	head := getTestHeadCommit(t, repo)
+
+	serialized, jsonErr := repo.TopCommitJSON()
+	if jsonErr != nil {
+		t.Fatal(jsonErr)
+	}
+	info := CommitInfo{}
+	if err := json.Unmarshal(serialized, &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Hash != head.Hash.String() || len(info.Parents) != 1 || info.Parents[0] != parent.Hash.String() {
+		t.Fatalf("Expected the top commit %s with parent %s, got %s with parents %v", head.Hash, parent.Hash, info.Hash, info.Parents)
+	}
+	if info.Author.Email != "author@example.com" || info.Committer.Email != "author@example.com" || info.Message != "Add b.txt" {
+		t.Fatalf("Unexpected commit metadata: %s", serialized)
+	}
+	if !info.Signed || info.Signer != nil {
+		t.Fatalf("Expected the commit to be signed without a verified signer, got %s", serialized)
+	}
+
+	verifier, verifierErr := NewVerifier([]string{getTestArmoredPublicKey(t, key)})
+	if verifierErr != nil {
+		t.Fatal(verifierErr)
+	}
+	serialized, jsonErr = repo.TopCommitJSONWithOptions(TopCommitJSONOptions{Verifier: verifier})
+	if jsonErr != nil {
+		t.Fatal(jsonErr)
+	}
+	info = CommitInfo{}
+	if err := json.Unmarshal(serialized, &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Signer == nil || info.Signer.Fingerprint != hex.EncodeToString(key.Entity.PrimaryKey.Fingerprint) {
+		t.Fatalf("Expected the verified signer to be included, got %s", serialized)
+	}
+}
@@ -0,0 +1,177 @@
+package git
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const knownHostsName = "known_hosts"
+
+type knownHostsLine struct {
+	marker   string
+	patterns []string
+	key      gossh.PublicKey
+	line     int
+}
+
+type knownHostsDb struct {
+	lines []knownHostsLine
+}
+
+func parseKnownHosts(content []byte) (*knownHostsDb, error) {
+	db := &knownHostsDb{}
+	for idx, rawLine := range bytes.Split(content, []byte("\n")) {
+		trimmed := bytes.TrimSpace(rawLine)
+		if len(trimmed) == 0 || trimmed[0] == '#' {
+			continue
+		}
+
+		marker, hosts, key, _, _, parseErr := gossh.ParseKnownHosts(trimmed)
+		if parseErr != nil {
+			return nil, fmt.Errorf("Error parsing line %d of known hosts: %w", idx+1, parseErr)
+		}
+
+		if marker != "" && marker != "cert-authority" && marker != "revoked" {
+			return nil, fmt.Errorf("Unsupported marker \"@%s\" at line %d of known hosts", marker, idx+1)
+		}
+
+		db.lines = append(db.lines, knownHostsLine{marker: marker, patterns: hosts, key: key, line: idx + 1})
+	}
+
+	return db, nil
+}
+
+//Hashed hosts have the "|1|salt|hash" form, where the hash is the hmac-sha1 of the normalized address keyed with the salt
+func matchHashedHost(pattern string, address string) bool {
+	parts := strings.Split(pattern, "|")
+	if len(parts) != 4 || parts[1] != "1" {
+		return false
+	}
+
+	salt, saltErr := base64.StdEncoding.DecodeString(parts[2])
+	if saltErr != nil {
+		return false
+	}
+
+	hash, hashErr := base64.StdEncoding.DecodeString(parts[3])
+	if hashErr != nil {
+		return false
+	}
+
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(address))
+	return hmac.Equal(mac.Sum(nil), hash)
+}
+
+func matchHostPattern(pattern string, address string) bool {
+	if strings.HasPrefix(pattern, "|") {
+		return matchHashedHost(pattern, address)
+	}
+
+	return matchWildcards(pattern, address)
+}
+
+//Matches the "*" and "?" wildcards of known hosts patterns. Other characters, including the brackets of "[host]:port" patterns, are matched literally
+func matchWildcards(pattern string, address string) bool {
+	if pattern == "" {
+		return address == ""
+	}
+
+	switch pattern[0] {
+	case '*':
+		for idx := 0; idx <= len(address); idx++ {
+			if matchWildcards(pattern[1:], address[idx:]) {
+				return true
+			}
+		}
+		return false
+	case '?':
+		return address != "" && matchWildcards(pattern[1:], address[1:])
+	default:
+		return address != "" && pattern[0] == address[0] && matchWildcards(pattern[1:], address[1:])
+	}
+}
+
+func (line *knownHostsLine) matches(address string) bool {
+	matched := false
+	for _, pattern := range line.patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		if !matchHostPattern(strings.TrimPrefix(pattern, "!"), address) {
+			continue
+		}
+
+		if negated {
+			return false
+		}
+		matched = true
+	}
+
+	return matched
+}
+
+func (line *knownHostsLine) knownKey() knownhosts.KnownKey {
+	return knownhosts.KnownKey{Key: line.key, Filename: knownHostsName, Line: line.line}
+}
+
+func (db *knownHostsDb) isRevoked(key gossh.PublicKey) *knownhosts.RevokedError {
+	for _, line := range db.lines {
+		if line.marker == "revoked" && bytes.Equal(line.key.Marshal(), key.Marshal()) {
+			return &knownhosts.RevokedError{Revoked: line.knownKey()}
+		}
+	}
+
+	return nil
+}
+
+func (db *knownHostsDb) checkHostKey(hostname string, remote net.Addr, key gossh.PublicKey) error {
+	revokedErr := db.isRevoked(key)
+	if revokedErr != nil {
+		return revokedErr
+	}
+
+	address := knownhosts.Normalize(hostname)
+	want := []knownhosts.KnownKey{}
+	for _, line := range db.lines {
+		if line.marker != "" || !line.matches(address) {
+			continue
+		}
+
+		if bytes.Equal(line.key.Marshal(), key.Marshal()) {
+			return nil
+		}
+		want = append(want, line.knownKey())
+	}
+
+	return &knownhosts.KeyError{Want: want}
+}
+
+func (db *knownHostsDb) isHostAuthority(authority gossh.PublicKey, address string) bool {
+	normalized := knownhosts.Normalize(address)
+	for _, line := range db.lines {
+		if line.marker == "cert-authority" && line.matches(normalized) && bytes.Equal(line.key.Marshal(), authority.Marshal()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (db *knownHostsDb) hostKeyCallback() gossh.HostKeyCallback {
+	checker := &gossh.CertChecker{
+		IsHostAuthority: db.isHostAuthority,
+		IsRevoked: func(cert *gossh.Certificate) bool {
+			return db.isRevoked(cert.SignatureKey) != nil
+		},
+		HostKeyFallback: db.checkHostKey,
+	}
+
+	return checker.CheckHostKey
+}
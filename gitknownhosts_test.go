@@ -0,0 +1,82 @@
+package git
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func newTestSshSigner(t *testing.T) gossh.Signer {
+	t.Helper()
+
+	_, private, keyErr := ed25519.GenerateKey(rand.Reader)
+	if keyErr != nil {
+		t.Fatal(keyErr)
+	}
+	signer, signerErr := gossh.NewSignerFromKey(private)
+	if signerErr != nil {
+		t.Fatal(signerErr)
+	}
+
+	return signer
+}
+
+func newTestHostCert(t *testing.T, hostKey gossh.PublicKey, authority gossh.Signer, principal string) *gossh.Certificate {
+	t.Helper()
+
+	cert := &gossh.Certificate{
+		Key:             hostKey,
+		CertType:        gossh.HostCert,
+		ValidPrincipals: []string{principal},
+		ValidBefore:     gossh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, authority); err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+func TestKnownHostsCertAuthority(t *testing.T) {
+	authority := newTestSshSigner(t)
+	otherAuthority := newTestSshSigner(t)
+	revokedAuthority := newTestSshSigner(t)
+	hostKey := newTestSshSigner(t).PublicKey()
+
+	content := "@cert-authority *.example.com " + string(gossh.MarshalAuthorizedKey(authority.PublicKey())) +
+		"@cert-authority *.example.com " + string(gossh.MarshalAuthorizedKey(revokedAuthority.PublicKey())) +
+		"@revoked * " + string(gossh.MarshalAuthorizedKey(revokedAuthority.PublicKey()))
+	db, parseErr := parseKnownHosts([]byte(content))
+	if parseErr != nil {
+		t.Fatal(parseErr)
+	}
+	callback := db.hostKeyCallback()
+	remote := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 22}
+
+	cases := []struct {
+		name     string
+		hostname string
+		key      gossh.PublicKey
+		accepted bool
+	}{
+		{"cert signed by listed authority", "git.example.com:22", newTestHostCert(t, hostKey, authority, "git.example.com"), true},
+		{"cert signed by other authority", "git.example.com:22", newTestHostCert(t, hostKey, otherAuthority, "git.example.com"), false},
+		{"cert signed by revoked authority", "git.example.com:22", newTestHostCert(t, hostKey, revokedAuthority, "git.example.com"), false},
+		{"cert for host outside authority patterns", "git.other.com:22", newTestHostCert(t, hostKey, authority, "git.other.com"), false},
+		{"cert for another principal", "git.example.com:22", newTestHostCert(t, hostKey, authority, "www.example.com"), false},
+		{"plain key of host", "git.example.com:22", hostKey, false},
+	}
+
+	for _, c := range cases {
+		err := callback(c.hostname, remote, c.key)
+		if c.accepted && err != nil {
+			t.Errorf("%s: expected host key to be accepted, got: %s", c.name, err)
+		}
+		if !c.accepted && err == nil {
+			t.Errorf("%s: expected host key to be rejected", c.name)
+		}
+	}
+}
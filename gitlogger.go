@@ -0,0 +1,28 @@
+package git
+
+/*
+Logger the sdk reports its progress to (ex: the branches that were cloned or the commits that were pushed).
+The standard library's *log.Logger satisfies it.
+*/
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+type noopLogger struct{}
+
+func (l noopLogger) Printf(format string, args ...interface{}) {}
+
+var logger Logger = noopLogger{}
+
+/*
+Sets the logger the sdk reports its progress to. By default, nothing is reported.
+Passing nil restores the default.
+*/
+func SetLogger(l Logger) {
+	if l == nil {
+		logger = noopLogger{}
+		return
+	}
+
+	logger = l
+}
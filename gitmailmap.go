@@ -0,0 +1,154 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+type mailmapEntry struct {
+	properName  string
+	properEmail string
+	commitName  string
+	commitEmail string
+}
+
+/*
+Mapping of the identities found in the history of a git repository to their canonical identity, as described in a .mailmap file
+*/
+type Mailmap struct {
+	entries []mailmapEntry
+}
+
+func parseMailmapIdentity(line string) (string, string, string, bool) {
+	start := strings.Index(line, "<")
+	if start == -1 {
+		return "", "", "", false
+	}
+
+	end := strings.Index(line[start:], ">")
+	if end == -1 {
+		return "", "", "", false
+	}
+	end = start + end
+
+	return strings.TrimSpace(line[:start]), strings.TrimSpace(line[start+1 : end]), line[end+1:], true
+}
+
+/*
+Parses the content of a .mailmap file. Lines that are not in one of the forms supported by git are ignored:
+  Proper Name <commit@email>
+  <proper@email> <commit@email>
+  Proper Name <proper@email> <commit@email>
+  Proper Name <proper@email> Commit Name <commit@email>
+*/
+func ParseMailmap(content string) *Mailmap {
+	mailmap := &Mailmap{entries: []mailmapEntry{}}
+
+	for _, line := range strings.Split(content, "\n") {
+		if commentIdx := strings.Index(line, "#"); commentIdx != -1 {
+			line = line[:commentIdx]
+		}
+
+		name, email, rest, ok := parseMailmapIdentity(line)
+		if !ok {
+			continue
+		}
+
+		commitName, commitEmail, _, hasCommitIdentity := parseMailmapIdentity(rest)
+		if !hasCommitIdentity {
+			mailmap.entries = append(mailmap.entries, mailmapEntry{properName: name, commitEmail: email})
+			continue
+		}
+
+		mailmap.entries = append(mailmap.entries, mailmapEntry{
+			properName:  name,
+			properEmail: email,
+			commitName:  commitName,
+			commitEmail: commitEmail,
+		})
+	}
+
+	return mailmap
+}
+
+/*
+Returns the canonical identity of the given signature. Like git, entries matching both the name and the email of the signature
+take precedence over entries matching only its email, and emails and names are compared case-insensitively.
+The date of the signature is kept as is and signatures that are not mapped are returned unchanged.
+*/
+func (m *Mailmap) Resolve(sig object.Signature) object.Signature {
+	var match *mailmapEntry
+	for idx := range m.entries {
+		entry := &m.entries[idx]
+		if !strings.EqualFold(entry.commitEmail, sig.Email) {
+			continue
+		}
+
+		if entry.commitName != "" {
+			if strings.EqualFold(entry.commitName, sig.Name) {
+				match = entry
+				break
+			}
+			continue
+		}
+
+		if match == nil {
+			match = entry
+		}
+	}
+
+	if match == nil {
+		return sig
+	}
+
+	if match.properName != "" {
+		sig.Name = match.properName
+	}
+	if match.properEmail != "" {
+		sig.Email = match.properEmail
+	}
+
+	return sig
+}
+
+/*
+Returns the mailmap defined by the .mailmap file at the root of the top commit of the git repository.
+If the repository doesn't have a .mailmap file, an empty mailmap that leaves all identities unchanged is returned.
+*/
+func GetMailmap(repo *GitRepository) (*Mailmap, error) {
+	commit, commitErr := getHeadCommit(repo)
+	if commitErr != nil {
+		return nil, commitErr
+	}
+
+	file, fileErr := commit.File(".mailmap")
+	if fileErr != nil {
+		if fileErr == object.ErrFileNotFound {
+			return ParseMailmap(""), nil
+		}
+
+		return nil, fmt.Errorf("Error accessing .mailmap file: %w", fileErr)
+	}
+
+	content, contentErr := file.Contents()
+	if contentErr != nil {
+		return nil, fmt.Errorf("Error reading .mailmap file: %w", contentErr)
+	}
+
+	return ParseMailmap(content), nil
+}
+
+/*
+Returns the canonical identity of the given signature (ex: the author of a commit) according to the .mailmap file of the git repository.
+When resolving many signatures, prefer calling GetMailmap once and resolving them with the returned mailmap.
+*/
+func ResolveIdentity(repo *GitRepository, sig object.Signature) (object.Signature, error) {
+	mailmap, mailmapErr := GetMailmap(repo)
+	if mailmapErr != nil {
+		return sig, mailmapErr
+	}
+
+	return mailmap.Resolve(sig), nil
+}
@@ -0,0 +1,50 @@
+package git
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const testMailmap = `# Comment lines are ignored: Ignored Name <commented@example.com>
+Proper One <commit1@example.com>
+<proper2@example.com> <commit2@example.com>
+Proper Three <proper3@example.com> <commit3@example.com> # Trailing comment
+Proper Four <proper4@example.com> Commit Four <commit4@example.com>
+Email Only <commit5@example.com>
+Name Match <proper5@example.com> Commit Five <commit5@example.com>
+Not a mailmap line
+`
+
+func TestParseMailmap(t *testing.T) {
+	mailmap := ParseMailmap(testMailmap)
+	when := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name          string
+		sigName       string
+		sigEmail      string
+		expectedName  string
+		expectedEmail string
+	}{
+		{"proper name", "Anyone", "commit1@example.com", "Proper One", "commit1@example.com"},
+		{"proper email", "Anyone", "COMMIT2@example.com", "Anyone", "proper2@example.com"},
+		{"proper name and email", "Anyone", "commit3@example.com", "Proper Three", "proper3@example.com"},
+		{"commit name and email", "commit four", "commit4@example.com", "Proper Four", "proper4@example.com"},
+		{"commit name mismatch", "Someone Else", "commit4@example.com", "Someone Else", "commit4@example.com"},
+		{"name match takes precedence", "Commit Five", "commit5@example.com", "Name Match", "proper5@example.com"},
+		{"email only fallback", "Someone Else", "commit5@example.com", "Email Only", "commit5@example.com"},
+		{"comment", "Anyone", "commented@example.com", "Anyone", "commented@example.com"},
+		{"unmapped", "Anyone", "unmapped@example.com", "Anyone", "unmapped@example.com"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolved := mailmap.Resolve(object.Signature{Name: test.sigName, Email: test.sigEmail, When: when})
+			if resolved.Name != test.expectedName || resolved.Email != test.expectedEmail || !resolved.When.Equal(when) {
+				t.Fatalf("Expected \"%s <%s>\" at %s, got \"%s <%s>\" at %s", test.expectedName, test.expectedEmail, when, resolved.Name, resolved.Email, resolved.When)
+			}
+		})
+	}
+}
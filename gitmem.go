@@ -1,16 +1,21 @@
 package git
 
 import (
-	"errors"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"path"
+	"sort"
 	"strings"
 
 	billy "github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
 	gogit "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage"
 	"github.com/go-git/go-git/v5/storage/memory"
 )
 
@@ -19,8 +24,10 @@ Container for a memory store. It used to keep a reference to the store and clear
 The Fs property is a pointer to a billy.Filesystem that can be used to intereract with the filesystem in memory
 */
 type MemoryStore struct {
-	storage *memory.Storage
-	Fs *billy.Filesystem
+	storage       storage.Storer
+	Fs            *billy.Filesystem
+	//Optional normalization applied to the content of text files written with the SetFileContent and SetContent methods
+	Normalization ContentNormalization
 }
 
 /*
@@ -42,6 +49,177 @@ func (mem *MemoryStore) GetKeyVals(sourcePath string) (map[string]string, error)
 	return keys, err
 }
 
+/*
+Path and content of a file in the memory filesystem, as returned by the GetSortedKeyVals method
+*/
+type KeyVal struct {
+	Key   string
+	Value string
+}
+
+/*
+Returns the same files as the GetKeyVals method, but as a list sorted by path so that it can be iterated over (ex: to serialize it) in a stable order.
+*/
+func (mem *MemoryStore) GetSortedKeyVals(sourcePath string) ([]KeyVal, error) {
+	keys, err := mem.GetKeyVals(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	keyVals := make([]KeyVal, 0, len(keys))
+	for key, value := range keys {
+		keyVals = append(keyVals, KeyVal{Key: key, Value: value})
+	}
+
+	sort.Slice(keyVals, func(i, j int) bool {
+		return keyVals[i].Key < keyVals[j].Key
+	})
+
+	return keyVals, nil
+}
+
+/*
+Returns a sha256 hash, in hexadecimal, of all the files returned by the GetKeyVals method for the given source path, combining their paths and contents in sorted order.
+The hash only changes if a file is added, removed, renamed or modified, so comparing it to the hash computed at the last commit is a cheap way to detect changes.
+*/
+func (mem *MemoryStore) ContentHash(sourcePath string) (string, error) {
+	keyVals, keyValsErr := mem.GetSortedKeyVals(sourcePath)
+	if keyValsErr != nil {
+		return "", keyValsErr
+	}
+
+	hash := sha256.New()
+	for _, keyVal := range keyVals {
+		//Lengths are included so that the boundaries between paths and contents are unambiguous
+		fmt.Fprintf(hash, "%d:%s:%d:", len(keyVal.Key), keyVal.Key, len(keyVal.Value))
+		hash.Write([]byte(keyVal.Value))
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+/*
+Writes the given content in the file at the given path in the memory filesystem, creating the file and its parent directories as needed.
+If the file already exists, its content is replaced. The normalization of the store, if any, is applied to the content.
+*/
+func (mem *MemoryStore) SetFileContent(filePath string, content string) error {
+	mkdirErr := (*mem.Fs).MkdirAll(path.Dir(filePath), 0755)
+	if mkdirErr != nil {
+		return fmt.Errorf("Error creating parent directory of file %s: %w", filePath, mkdirErr)
+	}
+
+	fWriter, createErr := (*mem.Fs).Create(filePath)
+	if createErr != nil {
+		return fmt.Errorf("Error creating file %s: %w", filePath, createErr)
+	}
+	defer fWriter.Close()
+
+	_, writeErr := fWriter.Write(normalizeContent([]byte(content), mem.Normalization))
+	if writeErr != nil {
+		return fmt.Errorf("Error writing content of file %s: %w", filePath, writeErr)
+	}
+
+	return nil
+}
+
+/*
+Same as SetFileContent, but the content is streamed from the given reader instead of being passed as a single string, which reduces the peak memory used to write large files.
+If the store has a normalization, the whole content needs to be read before it is normalized, so it is not streamed.
+*/
+func (mem *MemoryStore) SetFileContentFromReader(filePath string, r io.Reader) error {
+	if mem.Normalization != NoNormalization {
+		content, readErr := ioutil.ReadAll(r)
+		if readErr != nil {
+			return fmt.Errorf("Error reading content of file %s: %w", filePath, readErr)
+		}
+
+		return mem.SetFileContent(filePath, string(content))
+	}
+
+	mkdirErr := (*mem.Fs).MkdirAll(path.Dir(filePath), 0755)
+	if mkdirErr != nil {
+		return fmt.Errorf("Error creating parent directory of file %s: %w", filePath, mkdirErr)
+	}
+
+	fWriter, createErr := (*mem.Fs).Create(filePath)
+	if createErr != nil {
+		return fmt.Errorf("Error creating file %s: %w", filePath, createErr)
+	}
+	defer fWriter.Close()
+
+	_, copyErr := io.Copy(fWriter, r)
+	if copyErr != nil {
+		return fmt.Errorf("Error writing content of file %s: %w", filePath, copyErr)
+	}
+
+	return nil
+}
+
+/*
+Writes all the files of the given map in the memory filesystem in a single call, where the keys are the paths of the files and the values are their content.
+It is the counterpart of the GetKeyVals method.
+*/
+func (mem *MemoryStore) SetContent(files map[string]string) error {
+	filePaths := make([]string, 0, len(files))
+	for filePath := range files {
+		filePaths = append(filePaths, filePath)
+	}
+	sort.Strings(filePaths)
+
+	for _, filePath := range filePaths {
+		err := mem.SetFileContent(filePath, files[filePath])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+Removes the file at the given path from the memory filesystem, so that it shows up as a deletion when the path is committed.
+Returns an error if the path doesn't exist or is a directory, in which case DeleteDir should be used instead.
+*/
+func (mem *MemoryStore) DeleteFile(filePath string) error {
+	info, statErr := (*mem.Fs).Lstat(filePath)
+	if statErr != nil {
+		return fmt.Errorf("Error accessing file %s: %w", filePath, statErr)
+	}
+
+	if info.IsDir() {
+		return fmt.Errorf("Path %s is a directory and cannot be deleted as a file", filePath)
+	}
+
+	removeErr := (*mem.Fs).Remove(filePath)
+	if removeErr != nil {
+		return fmt.Errorf("Error deleting file %s: %w", filePath, removeErr)
+	}
+
+	return nil
+}
+
+/*
+Removes the directory at the given path from the memory filesystem along with all its content.
+Returns an error if the path doesn't exist or isn't a directory.
+*/
+func (mem *MemoryStore) DeleteDir(dirPath string) error {
+	info, statErr := (*mem.Fs).Lstat(dirPath)
+	if statErr != nil {
+		return fmt.Errorf("Error accessing directory %s: %w", dirPath, statErr)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("Path %s is not a directory", dirPath)
+	}
+
+	removeErr := util.RemoveAll(*mem.Fs, dirPath)
+	if removeErr != nil {
+		return fmt.Errorf("Error deleting directory %s: %w", dirPath, removeErr)
+	}
+
+	return nil
+}
+
 func stripsourcePath(fPath string, sourcePath string) string {
 	if sourcePath == "" {
 		return fPath
@@ -100,14 +278,23 @@ func buildKeySpace(fPath string, sourcePath string, store *MemoryStore, keys map
 /*
 Clone the given reference of a given repo in a memory filesystem.
 A reference to the generated filesystem as well as the repository is returned.
+Files changed through the memory store (ex: with SetFileContent or DeleteFile) can be committed with CommitFiles on the returned repository,
+using their paths relative to the root of the repository, exactly like for a repository on the filesystem.
 */
-func MemCloneGitRepo(url string, ref string, depth int, sshCred *SshCredentials) (*GitRepository, *MemoryStore, error) {
-	storer := memory.NewStorage()
-	fs := memfs.New()
-	store := MemoryStore{storer, &fs}
+func MemCloneGitRepo(url string, ref string, depth int, creds Credentials) (*GitRepository, *MemoryStore, error) {
+	repo, store, cloneErr := cloneGitRepoInto(url, ref, depth, creds, memory.NewStorage(), memfs.New())
+	if cloneErr != nil {
+		return repo, store, fmt.Errorf("Error cloning repo in memory: %w", cloneErr)
+	}
+
+	return repo, store, nil
+}
+
+func cloneGitRepoInto(url string, ref string, depth int, creds Credentials, storer storage.Storer, fs billy.Filesystem) (*GitRepository, *MemoryStore, error) {
+	store := MemoryStore{storage: storer, Fs: &fs}
 
 	repo, cloneErr := gogit.Clone(storer, fs, &gogit.CloneOptions{
-		Auth:              sshCred.Keys,
+		Auth:              creds.AuthMethod(),
 		RemoteName:        "origin",
 		URL:               url,
 		ReferenceName:     plumbing.NewBranchReferenceName(ref),
@@ -119,9 +306,53 @@ func MemCloneGitRepo(url string, ref string, depth int, sshCred *SshCredentials)
 		Tags:              gogit.NoTags,
 	})
 	if cloneErr != nil {
-		return &GitRepository{repo}, &store, errors.New(fmt.Sprintf("Error cloning repo in memory: %s", cloneErr.Error()))
+		return &GitRepository{Repo: repo}, &store, cloneErr
+	}
+
+	logger.Printf("Cloned branch \"%s\" of repo \"%s\"", ref, url)
+	return &GitRepository{Repo: repo}, &store, nil
+}
+
+/*
+Clone the given reference of a given repo using the git storage and worktree filesystem provided by the caller.
+This gives the caller control over the backing store (ex: a size-limited or encrypted filesystem).
+A memory store wrapping the provided storage and filesystem is returned along with the repository.
+*/
+func CloneGitRepoInto(url string, ref string, depth int, creds Credentials, storer storage.Storer, fs billy.Filesystem) (*GitRepository, *MemoryStore, error) {
+	repo, store, cloneErr := cloneGitRepoInto(url, ref, depth, creds, storer, fs)
+	if cloneErr != nil {
+		return repo, store, fmt.Errorf("Error cloning repo \"%s\": %w", url, cloneErr)
+	}
+
+	return repo, store, nil
+}
+
+/*
+Clones the given reference of a given repo in memory with a depth of 1, reads the file at the given path and unmarshals its content in the target
+with the given function (ex: json.Unmarshal or yaml.Unmarshal). The cloned repo is discarded once the file is read.
+*/
+func ReadRemoteFileInto(url string, ref string, filePath string, creds Credentials, target interface{}, unmarshal func([]byte, interface{}) error) error {
+	_, store, cloneErr := MemCloneGitRepo(url, ref, 1, creds)
+	if cloneErr != nil {
+		return cloneErr
+	}
+	defer store.Clear()
+
+	fReader, openErr := (*store.Fs).Open(filePath)
+	if openErr != nil {
+		return fmt.Errorf("Error opening file %s of repo \"%s\": %w", filePath, url, openErr)
 	}
+	defer fReader.Close()
 
-	fmt.Println(fmt.Sprintf("Cloned branch \"%s\" of repo \"%s\"", ref, url))
-	return &GitRepository{repo}, &store, nil
-}
\ No newline at end of file
+	content, readErr := ioutil.ReadAll(fReader)
+	if readErr != nil {
+		return fmt.Errorf("Error reading file %s of repo \"%s\": %w", filePath, url, readErr)
+	}
+
+	unmarshalErr := unmarshal(content, target)
+	if unmarshalErr != nil {
+		return fmt.Errorf("Error parsing file %s of repo \"%s\": %w", filePath, url, unmarshalErr)
+	}
+
+	return nil
+}
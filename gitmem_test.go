@@ -0,0 +1,160 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func TestCommitFilesInMemory(t *testing.T) {
+	remote := newTestRemote(t, map[string]string{"a.txt": "a\n", "keep.txt": "keep\n"})
+
+	repo, store, cloneErr := MemCloneGitRepo(remote, "main", 0, noCredentials{})
+	if cloneErr != nil {
+		t.Fatal(cloneErr)
+	}
+	defer store.Clear()
+
+	if err := store.SetFileContent("a.txt", "changed\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetFileContent("dir/b.txt", "b\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	committed, commitErr := CommitFiles(repo, []string{"a.txt", "dir/b.txt"}, "Change files in memory", testCommitOptions)
+	if commitErr != nil {
+		t.Fatal(commitErr)
+	}
+	if !committed {
+		t.Fatal("Expected changes to be committed")
+	}
+
+	head := getTestHeadCommit(t, repo)
+	if head.Message != "Change files in memory" || head.NumParents() != 1 {
+		t.Fatalf("Unexpected top commit %s with message %q", head.Hash, head.Message)
+	}
+
+	assertTestFiles(t, getTestTreeFiles(t, head), map[string]string{
+		"a.txt":     "changed\n",
+		"dir/b.txt": "b\n",
+		"keep.txt":  "keep\n",
+	})
+
+	committed, commitErr = CommitFiles(repo, []string{"a.txt", "dir/b.txt"}, "No changes", testCommitOptions)
+	if commitErr != nil || committed {
+		t.Fatalf("Expected no commit without changes, got %t and error %v", committed, commitErr)
+	}
+}
+
+func TestCommitFilesInMemoryAddsAndDeletes(t *testing.T) {
+	remote := newTestRemote(t, map[string]string{
+		"a.txt":         "a\n",
+		"removed.txt":   "removed\n",
+		"old/one.txt":   "one\n",
+		"old/two/2.txt": "two\n",
+		"keep.txt":      "keep\n",
+	})
+
+	repo, store, cloneErr := MemCloneGitRepo(remote, "main", 0, noCredentials{})
+	if cloneErr != nil {
+		t.Fatal(cloneErr)
+	}
+	defer store.Clear()
+
+	if err := store.SetContent(map[string]string{"a.txt": "changed\n", "new/b.txt": "b\n"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteFile("removed.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteDir("old"); err != nil {
+		t.Fatal(err)
+	}
+
+	committed, commitErr := CommitFiles(repo, []string{"a.txt", "new/b.txt", "removed.txt", "old"}, "Add and delete files in memory", testCommitOptions)
+	if commitErr != nil {
+		t.Fatal(commitErr)
+	}
+	if !committed {
+		t.Fatal("Expected changes to be committed")
+	}
+
+	assertTestFiles(t, getTestTreeFiles(t, getTestHeadCommit(t, repo)), map[string]string{
+		"a.txt":     "changed\n",
+		"new/b.txt": "b\n",
+		"keep.txt":  "keep\n",
+	})
+}
+
+func countTestObjects(t *testing.T, repo *GitRepository) (int, int) {
+	t.Helper()
+
+	objects, commits := 0, 0
+	iter, iterErr := repo.Repo.Storer.IterEncodedObjects(plumbing.AnyObject)
+	if iterErr != nil {
+		t.Fatal(iterErr)
+	}
+	err := iter.ForEach(func(obj plumbing.EncodedObject) error {
+		objects++
+		if obj.Type() == plumbing.CommitObject {
+			commits++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return objects, commits
+}
+
+func TestMemCloneGitRepoWithDepthReducesTransfer(t *testing.T) {
+	remote := newTestRemote(t, map[string]string{"config/app.yml": "version: 0\n", "large/data.txt": "0\n"})
+	for i := 1; i <= 5; i++ {
+		repo := commitInMemory(t, remote, "large/data.txt", strings.Repeat(fmt.Sprintf("%d\n", i), 10000))
+		if err := PushChanges(pushHook(repo), "main", noCredentials{}, 0, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	full, fullStore, fullErr := MemCloneGitRepo(remote, "main", 0, noCredentials{})
+	if fullErr != nil {
+		t.Fatal(fullErr)
+	}
+	defer fullStore.Clear()
+	shallow, shallowStore, shallowErr := MemCloneGitRepo(remote, "main", 1, noCredentials{})
+	if shallowErr != nil {
+		t.Fatal(shallowErr)
+	}
+	defer shallowStore.Clear()
+
+	fullObjects, fullCommits := countTestObjects(t, full)
+	shallowObjects, shallowCommits := countTestObjects(t, shallow)
+	if fullCommits != 6 || shallowCommits != 1 {
+		t.Fatalf("Expected 6 commits in the full clone and 1 in the shallow clone, got %d and %d", fullCommits, shallowCommits)
+	}
+	if shallowObjects >= fullObjects {
+		t.Fatalf("Expected the shallow clone to fetch fewer objects than the full clone, got %d and %d", shallowObjects, fullObjects)
+	}
+
+	shallowHashes, shallowHashesErr := shallow.Repo.Storer.Shallow()
+	if shallowHashesErr != nil {
+		t.Fatal(shallowHashesErr)
+	}
+	if len(shallowHashes) != 1 || shallowHashes[0] != getTestHeadCommit(t, shallow).Hash {
+		t.Fatalf("Expected the top commit to be the shallow boundary, got %v", shallowHashes)
+	}
+}
+
+func TestMemCloneGitRepoErrorText(t *testing.T) {
+	remote := newTestRemote(t, map[string]string{"a.txt": "a\n"})
+
+	_, _, cloneErr := MemCloneGitRepo(remote, "missing", 0, noCredentials{})
+	if cloneErr == nil || !strings.HasPrefix(cloneErr.Error(), "Error cloning repo in memory: ") || errors.Unwrap(cloneErr) == nil {
+		t.Fatalf("Expected a wrapped error cloning repo in memory, got %v", cloneErr)
+	}
+}
@@ -0,0 +1,76 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	gogit "github.com/go-git/go-git/v5"
+)
+
+/*
+Normalization applied to the content of text files before they are staged or written. Binary files are always left untouched.
+*/
+type ContentNormalization int
+
+const (
+	//The content is left as is
+	NoNormalization ContentNormalization = iota
+	//A newline is appended to non-empty content that doesn't already end with one
+	EnsureTrailingNewline
+	//Trailing spaces and tabs are stripped from every line and the content is made to end with a single newline, unless it is empty
+	StripTrailingWhitespace
+)
+
+func normalizeContent(content []byte, normalization ContentNormalization) []byte {
+	if normalization == NoNormalization || len(content) == 0 || isBinaryContent(content) {
+		return content
+	}
+
+	if normalization == StripTrailingWhitespace {
+		lines := bytes.Split(content, []byte("\n"))
+		for idx, line := range lines {
+			lines[idx] = bytes.TrimRight(line, " \t")
+		}
+		content = bytes.TrimRight(bytes.Join(lines, []byte("\n")), "\n")
+		if len(content) == 0 {
+			return content
+		}
+	}
+
+	if content[len(content)-1] != '\n' {
+		content = append(content, '\n')
+	}
+
+	return content
+}
+
+func normalizeWorktreeFile(w *gogit.Worktree, filePath string, normalization ContentNormalization) error {
+	if normalization == NoNormalization {
+		return nil
+	}
+
+	info, statErr := w.Filesystem.Lstat(filePath)
+	if statErr != nil {
+		if os.IsNotExist(statErr) {
+			return nil
+		}
+		return fmt.Errorf("Error accessing file %s in worktree: %w", filePath, statErr)
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	content, readErr := readWorktreeFile(w, filePath)
+	if readErr != nil {
+		return readErr
+	}
+
+	normalized := normalizeContent(content, normalization)
+	if bytes.Equal(content, normalized) {
+		return nil
+	}
+
+	return writeWorktreeFile(w, filePath, normalized, info.Mode())
+}
@@ -0,0 +1,273 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	gogit "github.com/go-git/go-git/v5"
+	gogitconf "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+/*
+Notes reference used when an empty notes reference is passed to the notes functions, like git does
+*/
+const DefaultNotesRef = "refs/notes/commits"
+
+/*
+Error returned by GetCommitNotes when the commit has no note in the given notes reference
+*/
+var ErrNoteNotFound = errors.New("The commit has no note.")
+
+func getNotesRefName(notesRef string) plumbing.ReferenceName {
+	if notesRef == "" {
+		return plumbing.ReferenceName(DefaultNotesRef)
+	}
+
+	return plumbing.ReferenceName(notesRef)
+}
+
+func getNotesCommit(repo *GitRepository, refName plumbing.ReferenceName) (*object.Commit, error) {
+	ref, refErr := repo.Repo.Reference(refName, true)
+	if refErr != nil {
+		if refErr == plumbing.ErrReferenceNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("Error accessing notes reference \"%s\": %w", refName, refErr)
+	}
+
+	commit, commitErr := repo.Repo.CommitObject(ref.Hash())
+	if commitErr != nil {
+		return nil, fmt.Errorf("Error accessing commit of notes reference \"%s\": %w", refName, commitErr)
+	}
+
+	return commit, nil
+}
+
+//Notes trees can be fanned out in sub-directories named after the leading characters of the annotated commit hashes (ex: "ab/cdef...")
+func getNotePaths(hash string) []string {
+	paths := []string{}
+	prefix := ""
+	rest := hash
+	for len(rest) > 2 {
+		paths = append(paths, prefix+rest)
+		prefix = prefix + rest[:2] + "/"
+		rest = rest[2:]
+	}
+
+	return paths
+}
+
+/*
+Returns the note attached to the commit with the given hash in the given notes reference (refs/notes/commits if empty).
+Notes are read from the local notes reference, so notes from the remote must be fetched with FetchNotes first.
+Returns an ErrNoteNotFound error if the commit has no note.
+*/
+func GetCommitNotes(repo *GitRepository, hash string, notesRef string) (string, error) {
+	refName := getNotesRefName(notesRef)
+	notesCommit, notesErr := getNotesCommit(repo, refName)
+	if notesErr != nil {
+		return "", notesErr
+	}
+
+	if notesCommit == nil {
+		return "", ErrNoteNotFound
+	}
+
+	tree, treeErr := notesCommit.Tree()
+	if treeErr != nil {
+		return "", fmt.Errorf("Error accessing tree of notes reference \"%s\": %w", refName, treeErr)
+	}
+
+	for _, notePath := range getNotePaths(hash) {
+		file, fileErr := tree.File(notePath)
+		if fileErr != nil {
+			if fileErr == object.ErrFileNotFound || fileErr == object.ErrDirectoryNotFound {
+				continue
+			}
+			return "", fmt.Errorf("Error accessing note of commit \"%s\": %w", hash, fileErr)
+		}
+
+		content, contentErr := file.Contents()
+		if contentErr != nil {
+			return "", fmt.Errorf("Error reading note of commit \"%s\": %w", hash, contentErr)
+		}
+
+		return content, nil
+	}
+
+	return "", ErrNoteNotFound
+}
+
+func storeNoteBlob(repo *GitRepository, note string) (plumbing.Hash, error) {
+	obj := repo.Repo.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+
+	writer, writerErr := obj.Writer()
+	if writerErr != nil {
+		return plumbing.ZeroHash, fmt.Errorf("Error encoding note: %w", writerErr)
+	}
+
+	_, writeErr := writer.Write([]byte(note))
+	if writeErr != nil {
+		writer.Close()
+		return plumbing.ZeroHash, fmt.Errorf("Error encoding note: %w", writeErr)
+	}
+
+	closeErr := writer.Close()
+	if closeErr != nil {
+		return plumbing.ZeroHash, fmt.Errorf("Error encoding note: %w", closeErr)
+	}
+
+	hash, storeErr := repo.Repo.Storer.SetEncodedObject(obj)
+	if storeErr != nil {
+		return plumbing.ZeroHash, fmt.Errorf("Error storing note: %w", storeErr)
+	}
+
+	return hash, nil
+}
+
+func storeTree(repo *GitRepository, tree *object.Tree) (plumbing.Hash, error) {
+	obj := repo.Repo.Storer.NewEncodedObject()
+	encErr := tree.Encode(obj)
+	if encErr != nil {
+		return plumbing.ZeroHash, fmt.Errorf("Error encoding tree: %w", encErr)
+	}
+
+	hash, storeErr := repo.Repo.Storer.SetEncodedObject(obj)
+	if storeErr != nil {
+		return plumbing.ZeroHash, fmt.Errorf("Error storing tree: %w", storeErr)
+	}
+
+	return hash, nil
+}
+
+/*
+Attaches the given note to the commit with the given hash in the given notes reference (refs/notes/commits if empty), replacing its previous note if any.
+An empty note removes the note of the commit. The notes are committed locally with the author, committer and signature key of the commit options
+and can then be pushed with PushNotes. Notes trees fanned out in sub-directories, as git does for large numbers of notes, are not supported.
+*/
+func SetCommitNote(repo *GitRepository, hash string, notesRef string, note string, opts CommitOptions) error {
+	commit, commitErr := repo.Repo.CommitObject(plumbing.NewHash(hash))
+	if commitErr != nil {
+		return fmt.Errorf("Error accessing commit \"%s\": %w", hash, commitErr)
+	}
+	noteName := commit.Hash.String()
+
+	refName := getNotesRefName(notesRef)
+	notesCommit, notesErr := getNotesCommit(repo, refName)
+	if notesErr != nil {
+		return notesErr
+	}
+
+	entries := []object.TreeEntry{}
+	parents := []plumbing.Hash{}
+	if notesCommit != nil {
+		tree, treeErr := notesCommit.Tree()
+		if treeErr != nil {
+			return fmt.Errorf("Error accessing tree of notes reference \"%s\": %w", refName, treeErr)
+		}
+
+		for _, entry := range tree.Entries {
+			if entry.Mode == filemode.Dir {
+				return fmt.Errorf("Notes reference \"%s\" is fanned out in sub-directories, which is not supported", refName)
+			}
+
+			if entry.Name != noteName {
+				entries = append(entries, entry)
+			}
+		}
+		parents = append(parents, notesCommit.Hash)
+	}
+
+	if note != "" {
+		blobHash, blobErr := storeNoteBlob(repo, note)
+		if blobErr != nil {
+			return blobErr
+		}
+
+		entries = append(entries, object.TreeEntry{Name: noteName, Mode: filemode.Regular, Hash: blobHash})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	treeHash, treeErr := storeTree(repo, &object.Tree{Entries: entries})
+	if treeErr != nil {
+		return treeErr
+	}
+
+	author, committer, authorErr := resolveAuthorAndCommitter(repo, opts)
+	if authorErr != nil {
+		return authorErr
+	}
+
+	newNotesCommit := &object.Commit{
+		Author:       *author,
+		Committer:    *committer,
+		Message:      "Notes added by SetCommitNote\n",
+		TreeHash:     treeHash,
+		ParentHashes: parents,
+	}
+
+	if opts.SignatureKey != nil {
+		signErr := signCommit(newNotesCommit, opts.SignatureKey, opts.SignatureHash)
+		if signErr != nil {
+			return signErr
+		}
+	}
+
+	newNotesHash, storeErr := storeCommit(repo, newNotesCommit)
+	if storeErr != nil {
+		return storeErr
+	}
+
+	setErr := repo.Repo.Storer.SetReference(plumbing.NewHashReference(refName, newNotesHash))
+	if setErr != nil {
+		return fmt.Errorf("Error updating notes reference \"%s\" to commit %s: %w", refName, newNotesHash, setErr)
+	}
+
+	logger.Printf("Set note of commit \"%s\" in notes reference \"%s\"", noteName, refName)
+	repo.recordOperation(CommitOperation, fmt.Sprintf("Set note of commit %s in notes reference \"%s\"", noteName, refName))
+	return nil
+}
+
+/*
+Fetches the given notes reference (refs/notes/commits if empty) from origin so that its notes can be read with GetCommitNotes.
+The fetch is not forced, so it fails if the local notes diverged from the remote ones.
+*/
+func FetchNotes(repo *GitRepository, notesRef string, creds Credentials) error {
+	refName := getNotesRefName(notesRef)
+	return FetchRefSpecs(repo, []string{fmt.Sprintf("%s:%s", refName, refName)}, creds)
+}
+
+/*
+Pushes the given notes reference (refs/notes/commits if empty) to origin.
+The push is not forced, so it fails if the remote notes diverged from the local ones.
+*/
+func PushNotes(repo *GitRepository, notesRef string, creds Credentials) error {
+	refName := getNotesRefName(notesRef)
+	pushErr := repo.Repo.Push(&gogit.PushOptions{
+		Auth:       creds.AuthMethod(),
+		Force:      false,
+		Prune:      false,
+		RemoteName: "origin",
+		RefSpecs:   []gogitconf.RefSpec{gogitconf.RefSpec(fmt.Sprintf("%s:%s", refName, refName))},
+	})
+	if pushErr != nil {
+		if pushErr.Error() == gogit.NoErrAlreadyUpToDate.Error() {
+			logger.Printf("Notes reference \"%s\" is already up to date on origin", refName)
+			return nil
+		}
+
+		return fmt.Errorf("Error pushing notes reference \"%s\": %w", refName, pushErr)
+	}
+
+	logger.Printf("Pushed notes reference \"%s\"", refName)
+	repo.recordOperation(PushOperation, fmt.Sprintf("Pushed notes reference \"%s\" to origin", refName))
+	return nil
+}
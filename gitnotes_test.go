@@ -0,0 +1,110 @@
+package git
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestSetAndGetCommitNotes(t *testing.T) {
+	repo, commits := newTestHistoryRepo(t)
+	hash := commits[1].Hash.String()
+
+	if _, err := GetCommitNotes(repo, hash, ""); !errors.Is(err, ErrNoteNotFound) {
+		t.Fatalf("Expected an ErrNoteNotFound error before any note is set, got %v", err)
+	}
+
+	if err := SetCommitNote(repo, hash, "", "first note\n", testCommitOptions); err != nil {
+		t.Fatal(err)
+	}
+	if note, err := GetCommitNotes(repo, hash, ""); err != nil || note != "first note\n" {
+		t.Fatalf("Expected the note to be read back, got %q (%v)", note, err)
+	}
+
+	if err := SetCommitNote(repo, hash, "", "second note\n", testCommitOptions); err != nil {
+		t.Fatal(err)
+	}
+	if note, err := GetCommitNotes(repo, hash, DefaultNotesRef); err != nil || note != "second note\n" {
+		t.Fatalf("Expected the note to be overwritten, got %q (%v)", note, err)
+	}
+
+	notesCommit, notesErr := getNotesCommit(repo, plumbing.ReferenceName(DefaultNotesRef))
+	if notesErr != nil {
+		t.Fatal(notesErr)
+	}
+	if len(notesCommit.ParentHashes) != 1 {
+		t.Fatalf("Expected the notes commits to be chained, got parents %v", notesCommit.ParentHashes)
+	}
+	assertTestFiles(t, getTestTreeFiles(t, notesCommit), map[string]string{hash: "second note\n"})
+
+	//Notes of other commits and in other notes references are distinct
+	if _, err := GetCommitNotes(repo, commits[2].Hash.String(), ""); !errors.Is(err, ErrNoteNotFound) {
+		t.Fatalf("Expected an ErrNoteNotFound error for a commit without a note, got %v", err)
+	}
+	if _, err := GetCommitNotes(repo, hash, "refs/notes/review"); !errors.Is(err, ErrNoteNotFound) {
+		t.Fatalf("Expected an ErrNoteNotFound error in another notes reference, got %v", err)
+	}
+
+	if err := SetCommitNote(repo, hash, "", "", testCommitOptions); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetCommitNotes(repo, hash, ""); !errors.Is(err, ErrNoteNotFound) {
+		t.Fatalf("Expected an empty note to remove the note, got %v", err)
+	}
+}
+
+//Stores a notes reference whose tree is fanned out in a sub-directory named after the first two characters of the commit hash, as git does for large numbers of notes
+func storeTestFannedOutNotes(t *testing.T, repo *GitRepository, refName string, hash string, note string) plumbing.Hash {
+	t.Helper()
+
+	subTree := storeTestObject(t, repo, &object.Tree{Entries: []object.TreeEntry{
+		{Name: hash[2:], Mode: filemode.Regular, Hash: storeTestBlob(t, repo, note)},
+	}})
+	tree := storeTestObject(t, repo, &object.Tree{Entries: []object.TreeEntry{
+		{Name: hash[:2], Mode: filemode.Dir, Hash: subTree},
+	}})
+
+	signature := object.Signature{Name: "Test Author", Email: "author@example.com", When: time.Now()}
+	commit := storeTestObject(t, repo, &object.Commit{
+		Author:    signature,
+		Committer: signature,
+		Message:   "Notes added by git\n",
+		TreeHash:  tree,
+	})
+	if err := repo.Repo.Storer.SetReference(plumbing.NewHashReference(plumbing.ReferenceName(refName), commit)); err != nil {
+		t.Fatal(err)
+	}
+
+	return commit
+}
+
+func TestCommitNotesFanOut(t *testing.T) {
+	repo, commits := newTestHistoryRepo(t)
+	hash := commits[1].Hash.String()
+	notesCommit := storeTestFannedOutNotes(t, repo, DefaultNotesRef, hash, "fanned out note\n")
+
+	if note, err := GetCommitNotes(repo, hash, ""); err != nil || note != "fanned out note\n" {
+		t.Fatalf("Expected the note to be read from the fanned out tree, got %q (%v)", note, err)
+	}
+	if _, err := GetCommitNotes(repo, commits[2].Hash.String(), ""); !errors.Is(err, ErrNoteNotFound) {
+		t.Fatalf("Expected an ErrNoteNotFound error for a commit without a note, got %v", err)
+	}
+
+	setErr := SetCommitNote(repo, commits[2].Hash.String(), "", "new note\n", testCommitOptions)
+	if setErr == nil || !strings.Contains(setErr.Error(), "fanned out") {
+		t.Fatalf("Expected an error setting a note in a fanned out notes reference, got %v", setErr)
+	}
+
+	ref, refErr := repo.Repo.Reference(plumbing.ReferenceName(DefaultNotesRef), true)
+	if refErr != nil {
+		t.Fatal(refErr)
+	}
+	if ref.Hash() != notesCommit {
+		t.Fatalf("Expected the notes reference to be left at commit %s, got %s", notesCommit, ref.Hash())
+	}
+}
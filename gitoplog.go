@@ -0,0 +1,133 @@
+package git
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+Kind of mutating operation recorded in an operation log
+*/
+type OperationKind string
+
+const (
+	CloneOperation    OperationKind = "clone"
+	PullOperation     OperationKind = "pull"
+	FetchOperation    OperationKind = "fetch"
+	CommitOperation   OperationKind = "commit"
+	RewriteOperation  OperationKind = "rewrite"
+	CheckoutOperation OperationKind = "checkout"
+	PushOperation     OperationKind = "push"
+)
+
+/*
+Entry of an operation log describing a mutating operation performed by the sdk on a git repository
+*/
+type Operation struct {
+	Kind        OperationKind
+	Time        time.Time
+	//Hash of the top commit of the repository after the operation, if it could be determined
+	Commit      string
+	//Human readable description of the operation
+	Description string
+}
+
+/*
+Audit trail of the mutating operations (clones, pulls, commits, pushes, etc) performed by the sdk on the git repositories it is attached to.
+It is distinct from the reflog of git and is only kept in memory. It is safe for concurrent use.
+*/
+type OperationLog struct {
+	mutex      sync.Mutex
+	operations []Operation
+}
+
+/*
+Returns an empty operation log
+*/
+func NewOperationLog() *OperationLog {
+	return &OperationLog{operations: []Operation{}}
+}
+
+/*
+Returns a copy of the operations recorded in the log, oldest first
+*/
+func (log *OperationLog) Operations() []Operation {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	operations := make([]Operation, len(log.operations))
+	copy(operations, log.operations)
+	return operations
+}
+
+func (log *OperationLog) record(operation Operation) {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+	log.operations = append(log.operations, operation)
+}
+
+//The logs are kept outside of the GitRepository structure so that adding them didn't change its fields
+var operationLogs = map[*GitRepository]*OperationLog{}
+var operationLogsMutex sync.Mutex
+
+func (repo *GitRepository) getOperationLog() *OperationLog {
+	operationLogsMutex.Lock()
+	defer operationLogsMutex.Unlock()
+	return operationLogs[repo]
+}
+
+/*
+Attaches an operation log to the git repository so that the mutating operations subsequently performed on it by the sdk are recorded.
+The same log can be attached to several repositories. Attaching a nil log detaches the current one, which should be done once the repository
+is no longer used so that it can be garbage collected.
+*/
+func (repo *GitRepository) AttachOperationLog(log *OperationLog) {
+	operationLogsMutex.Lock()
+	defer operationLogsMutex.Unlock()
+
+	if log == nil {
+		delete(operationLogs, repo)
+		return
+	}
+
+	operationLogs[repo] = log
+}
+
+/*
+Returns the operations recorded in the operation log attached to the git repository, oldest first.
+Returns nil if no log is attached.
+*/
+func (repo *GitRepository) History() []Operation {
+	log := repo.getOperationLog()
+	if log == nil {
+		return nil
+	}
+
+	return log.Operations()
+}
+
+func (repo *GitRepository) recordOperation(kind OperationKind, description string) {
+	if repo == nil {
+		return
+	}
+
+	log := repo.getOperationLog()
+	if log == nil {
+		return
+	}
+
+	commit := ""
+	if repo.Repo != nil {
+		head, headErr := repo.Repo.Head()
+		if headErr == nil {
+			commit = head.Hash().String()
+		}
+	}
+
+	log.record(Operation{
+		Kind:        kind,
+		Time:        time.Now(),
+		Commit:      commit,
+		Description: description,
+	})
+}
@@ -0,0 +1,398 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+Error returned by ApplyPatch when some hunks of the patch could not be applied.
+It lists the files whose hunks failed to apply.
+*/
+type PatchConflictError struct {
+	Files []string
+}
+
+func (e *PatchConflictError) Error() string {
+	return fmt.Sprintf("Patch could not be applied cleanly to the following files: %s", strings.Join(e.Files, ", "))
+}
+
+type patchHunkLine struct {
+	op   byte
+	text string
+}
+
+type patchHunk struct {
+	oldStart int
+	oldLines int
+	newLines int
+	lines    []patchHunkLine
+	oldNoEol bool
+	newNoEol bool
+}
+
+type filePatch struct {
+	oldPath string
+	newPath string
+	binary  bool
+	hunks   []*patchHunk
+}
+
+func parsePatchPath(header string) string {
+	filePath := strings.TrimSpace(header)
+	if tabIdx := strings.Index(filePath, "\t"); tabIdx != -1 {
+		filePath = filePath[:tabIdx]
+	}
+
+	if filePath == "/dev/null" {
+		return ""
+	}
+
+	if strings.HasPrefix(filePath, "a/") || strings.HasPrefix(filePath, "b/") {
+		return filePath[2:]
+	}
+
+	return filePath
+}
+
+func parseHunkRange(hunkRange string) (int, int, error) {
+	parts := strings.SplitN(hunkRange, ",", 2)
+	start, startErr := strconv.Atoi(parts[0])
+	if startErr != nil {
+		return 0, 0, startErr
+	}
+
+	if len(parts) == 1 {
+		return start, 1, nil
+	}
+
+	length, lengthErr := strconv.Atoi(parts[1])
+	if lengthErr != nil {
+		return 0, 0, lengthErr
+	}
+
+	return start, length, nil
+}
+
+func parseHunkHeader(header string) (*patchHunk, error) {
+	fields := strings.Fields(header)
+	if len(fields) < 4 || fields[0] != "@@" || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return nil, fmt.Errorf("Malformed hunk header \"%s\"", header)
+	}
+
+	oldStart, oldLines, oldErr := parseHunkRange(fields[1][1:])
+	if oldErr != nil {
+		return nil, fmt.Errorf("Malformed hunk header \"%s\": %w", header, oldErr)
+	}
+
+	_, newLines, newErr := parseHunkRange(fields[2][1:])
+	if newErr != nil {
+		return nil, fmt.Errorf("Malformed hunk header \"%s\": %w", header, newErr)
+	}
+
+	return &patchHunk{oldStart: oldStart, oldLines: oldLines, newLines: newLines}, nil
+}
+
+func markNoEol(hunk *patchHunk) {
+	if len(hunk.lines) == 0 {
+		return
+	}
+
+	lastOp := hunk.lines[len(hunk.lines)-1].op
+	if lastOp != '+' {
+		hunk.oldNoEol = true
+	}
+	if lastOp != '-' {
+		hunk.newNoEol = true
+	}
+}
+
+func parsePatch(patch string) ([]*filePatch, error) {
+	patches := []*filePatch{}
+	var current *filePatch
+	var hunk *patchHunk
+	oldRemaining := 0
+	newRemaining := 0
+
+	lines := strings.Split(patch, "\n")
+	for idx := 0; idx < len(lines); idx++ {
+		line := lines[idx]
+
+		if hunk != nil && (oldRemaining > 0 || newRemaining > 0) {
+			if line == "" && idx == len(lines)-1 {
+				break
+			}
+
+			if strings.HasPrefix(line, "\\") {
+				markNoEol(hunk)
+				continue
+			}
+
+			op := byte(' ')
+			text := ""
+			if len(line) > 0 {
+				op = line[0]
+				text = line[1:]
+			}
+
+			switch op {
+			case ' ':
+				oldRemaining--
+				newRemaining--
+			case '-':
+				oldRemaining--
+			case '+':
+				newRemaining--
+			default:
+				return nil, fmt.Errorf("Malformed hunk line \"%s\" in patch of file %s", line, current.newPath)
+			}
+
+			hunk.lines = append(hunk.lines, patchHunkLine{op, text})
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "\\"):
+			if hunk != nil {
+				markNoEol(hunk)
+			}
+		case strings.HasPrefix(line, "diff --git "):
+			fields := strings.Fields(strings.TrimPrefix(line, "diff --git "))
+			current = &filePatch{}
+			if len(fields) == 2 {
+				current.oldPath = parsePatchPath(fields[0])
+				current.newPath = parsePatchPath(fields[1])
+			}
+			patches = append(patches, current)
+			hunk = nil
+		case strings.HasPrefix(line, "--- "):
+			if current == nil || len(current.hunks) > 0 {
+				current = &filePatch{}
+				patches = append(patches, current)
+			}
+			current.oldPath = parsePatchPath(strings.TrimPrefix(line, "--- "))
+			hunk = nil
+		case strings.HasPrefix(line, "+++ "):
+			if current == nil {
+				return nil, errors.New("Malformed patch: \"+++\" header without a preceding \"---\" header")
+			}
+			current.newPath = parsePatchPath(strings.TrimPrefix(line, "+++ "))
+		case strings.HasPrefix(line, "new file mode"):
+			if current != nil {
+				current.oldPath = ""
+			}
+		case strings.HasPrefix(line, "deleted file mode"):
+			if current != nil {
+				current.newPath = ""
+			}
+		case strings.HasPrefix(line, "rename from "):
+			if current != nil {
+				current.oldPath = strings.TrimPrefix(line, "rename from ")
+			}
+		case strings.HasPrefix(line, "rename to "):
+			if current != nil {
+				current.newPath = strings.TrimPrefix(line, "rename to ")
+			}
+		case strings.HasPrefix(line, "Binary files ") || strings.HasPrefix(line, "GIT binary patch"):
+			if current != nil {
+				current.binary = true
+			}
+		case strings.HasPrefix(line, "@@ "):
+			if current == nil {
+				return nil, errors.New("Malformed patch: hunk without a preceding file header")
+			}
+
+			parsedHunk, hunkErr := parseHunkHeader(line)
+			if hunkErr != nil {
+				return nil, hunkErr
+			}
+
+			hunk = parsedHunk
+			oldRemaining = hunk.oldLines
+			newRemaining = hunk.newLines
+			current.hunks = append(current.hunks, hunk)
+		}
+	}
+
+	if hunk != nil && (oldRemaining > 0 || newRemaining > 0) {
+		return nil, fmt.Errorf("Malformed patch: last hunk of file %s is truncated", current.newPath)
+	}
+
+	return patches, nil
+}
+
+func splitPatchContent(content string) ([]string, bool) {
+	if content == "" {
+		return []string{}, false
+	}
+
+	lines := strings.Split(content, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1], false
+	}
+
+	return lines, true
+}
+
+func hunkMatchesAt(lines []string, oldSeq []string, pos int) bool {
+	if pos < 0 || pos+len(oldSeq) > len(lines) {
+		return false
+	}
+
+	for idx, oldLine := range oldSeq {
+		if lines[pos+idx] != oldLine {
+			return false
+		}
+	}
+
+	return true
+}
+
+func applyFilePatch(content string, fPatch *filePatch) (string, bool) {
+	lines, noEol := splitPatchContent(content)
+	result := []string{}
+	lastEnd := 0
+
+	for _, hunk := range fPatch.hunks {
+		oldSeq := []string{}
+		newSeq := []string{}
+		for _, hunkLine := range hunk.lines {
+			if hunkLine.op != '+' {
+				oldSeq = append(oldSeq, hunkLine.text)
+			}
+			if hunkLine.op != '-' {
+				newSeq = append(newSeq, hunkLine.text)
+			}
+		}
+
+		expected := hunk.oldStart - 1
+		if hunk.oldLines == 0 {
+			expected = hunk.oldStart
+		}
+
+		pos := -1
+		for offset := 0; offset <= len(lines); offset++ {
+			if expected-offset >= lastEnd && hunkMatchesAt(lines, oldSeq, expected-offset) {
+				pos = expected - offset
+				break
+			}
+			if expected+offset >= lastEnd && hunkMatchesAt(lines, oldSeq, expected+offset) {
+				pos = expected + offset
+				break
+			}
+		}
+		if pos == -1 {
+			return "", false
+		}
+
+		result = append(result, lines[lastEnd:pos]...)
+		result = append(result, newSeq...)
+		lastEnd = pos + len(oldSeq)
+
+		if lastEnd == len(lines) {
+			noEol = hunk.newNoEol
+		}
+	}
+	result = append(result, lines[lastEnd:]...)
+
+	if len(result) == 0 {
+		return "", true
+	}
+
+	patched := strings.Join(result, "\n")
+	if !noEol {
+		patched = patched + "\n"
+	}
+
+	return patched, true
+}
+
+/*
+Parses a patch in the unified diff format (as produced by "git diff" or "diff -u") and applies it to the worktree of the git repository.
+Works for both repositories on the filesystem and in memory. The changes are not staged and can then be committed with CommitFiles or CommitAllChanges.
+The patch is applied atomically: if the hunks of any file fail to apply, no file is modified and a *PatchConflictError listing the affected files is returned.
+*/
+func ApplyPatch(repo *GitRepository, patch []byte) error {
+	w, wErr := repo.Repo.Worktree()
+	if wErr != nil {
+		return fmt.Errorf("Error accessing repo worktree: %w", wErr)
+	}
+
+	patches, parseErr := parsePatch(string(patch))
+	if parseErr != nil {
+		return fmt.Errorf("Error parsing patch: %w", parseErr)
+	}
+
+	type patchResult struct {
+		fPatch  *filePatch
+		content string
+		mode    os.FileMode
+	}
+
+	results := []patchResult{}
+	conflicts := []string{}
+	for _, fPatch := range patches {
+		if fPatch.binary {
+			return fmt.Errorf("Error applying patch: binary patches are not supported (file %s)", fPatch.newPath)
+		}
+
+		content := ""
+		mode := os.FileMode(0644)
+		if fPatch.oldPath != "" {
+			info, statErr := w.Filesystem.Lstat(fPatch.oldPath)
+			if statErr != nil {
+				conflicts = append(conflicts, fPatch.oldPath)
+				continue
+			}
+			mode = info.Mode()
+
+			oldContent, readErr := readWorktreeFile(w, fPatch.oldPath)
+			if readErr != nil {
+				return readErr
+			}
+			content = string(oldContent)
+		} else if _, statErr := w.Filesystem.Lstat(fPatch.newPath); statErr == nil {
+			conflicts = append(conflicts, fPatch.newPath)
+			continue
+		}
+
+		patched, applied := applyFilePatch(content, fPatch)
+		if !applied {
+			conflictPath := fPatch.newPath
+			if conflictPath == "" {
+				conflictPath = fPatch.oldPath
+			}
+			conflicts = append(conflicts, conflictPath)
+			continue
+		}
+
+		results = append(results, patchResult{fPatch, patched, mode})
+	}
+
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return &PatchConflictError{Files: conflicts}
+	}
+
+	for _, result := range results {
+		if result.fPatch.newPath != "" {
+			writeErr := writeWorktreeFile(w, result.fPatch.newPath, []byte(result.content), result.mode)
+			if writeErr != nil {
+				return writeErr
+			}
+		}
+
+		if result.fPatch.oldPath != "" && result.fPatch.oldPath != result.fPatch.newPath {
+			removeErr := w.Filesystem.Remove(result.fPatch.oldPath)
+			if removeErr != nil {
+				return fmt.Errorf("Error removing file %s from worktree: %w", result.fPatch.oldPath, removeErr)
+			}
+		}
+	}
+
+	return nil
+}
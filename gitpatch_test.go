@@ -0,0 +1,119 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPatchLines = "l1\nl2\nl3\nl4\nl5\nl6\nl7\nl8\nl9\nl10\n"
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string]string
+		patch     string
+		expected  map[string]string
+		deleted   []string
+		conflicts []string
+		errText   string
+	}{
+		{
+			name:  "multiple hunks",
+			files: map[string]string{"f.txt": testPatchLines},
+			patch: "--- a/f.txt\n+++ b/f.txt\n" +
+				"@@ -1,3 +1,3 @@\n l1\n-l2\n+L2\n l3\n" +
+				"@@ -8,3 +8,3 @@\n l8\n-l9\n+L9\n l10\n",
+			expected: map[string]string{"f.txt": "l1\nL2\nl3\nl4\nl5\nl6\nl7\nl8\nL9\nl10\n"},
+		},
+		{
+			name:     "hunk after its expected position",
+			files:    map[string]string{"f.txt": "x1\nx2\n" + testPatchLines},
+			patch:    "--- a/f.txt\n+++ b/f.txt\n@@ -1,3 +1,3 @@\n l1\n-l2\n+L2\n l3\n",
+			expected: map[string]string{"f.txt": "x1\nx2\nl1\nL2\nl3\nl4\nl5\nl6\nl7\nl8\nl9\nl10\n"},
+		},
+		{
+			name:     "hunk before its expected position",
+			files:    map[string]string{"f.txt": "l3\nl4\nl5\nl6\nl7\nl8\nl9\nl10\n"},
+			patch:    "--- a/f.txt\n+++ b/f.txt\n@@ -8,3 +8,3 @@\n l8\n-l9\n+L9\n l10\n",
+			expected: map[string]string{"f.txt": "l3\nl4\nl5\nl6\nl7\nl8\nL9\nl10\n"},
+		},
+		{
+			name:  "new file",
+			files: map[string]string{"f.txt": testPatchLines},
+			patch: "diff --git a/new.txt b/new.txt\nnew file mode 100644\nindex 0000000..e69de29\n" +
+				"--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1,2 @@\n+n1\n+n2\n",
+			expected: map[string]string{"f.txt": testPatchLines, "new.txt": "n1\nn2\n"},
+		},
+		{
+			name:  "deleted file",
+			files: map[string]string{"f.txt": testPatchLines, "a.txt": "a1\na2\n"},
+			patch: "diff --git a/a.txt b/a.txt\ndeleted file mode 100644\n" +
+				"--- a/a.txt\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-a1\n-a2\n",
+			expected: map[string]string{"f.txt": testPatchLines},
+			deleted:  []string{"a.txt"},
+		},
+		{
+			name:     "newline removed at end of file",
+			files:    map[string]string{"f.txt": "l1\nl2\n"},
+			patch:    "--- a/f.txt\n+++ b/f.txt\n@@ -1,2 +1,2 @@\n l1\n-l2\n+L2\n\\ No newline at end of file\n",
+			expected: map[string]string{"f.txt": "l1\nL2"},
+		},
+		{
+			name:     "newline added at end of file",
+			files:    map[string]string{"f.txt": "l1\nl2"},
+			patch:    "--- a/f.txt\n+++ b/f.txt\n@@ -1,2 +1,2 @@\n l1\n-l2\n\\ No newline at end of file\n+l2\n",
+			expected: map[string]string{"f.txt": "l1\nl2\n"},
+		},
+		{
+			name:     "binary patch",
+			files:    map[string]string{"f.txt": testPatchLines},
+			patch:    "diff --git a/img.png b/img.png\nindex 1234567..89abcde 100644\nBinary files a/img.png and b/img.png differ\n",
+			expected: map[string]string{"f.txt": testPatchLines},
+			errText:  "binary patches are not supported",
+		},
+		{
+			name:  "conflict",
+			files: map[string]string{"f.txt": testPatchLines, "b.txt": "b1\nb2\n"},
+			patch: "--- a/f.txt\n+++ b/f.txt\n@@ -1,3 +1,3 @@\n l1\n-l2\n+L2\n l3\n" +
+				"--- a/b.txt\n+++ b/b.txt\n@@ -1,2 +1,2 @@\n b1\n-other\n+B2\n",
+			expected:  map[string]string{"f.txt": testPatchLines, "b.txt": "b1\nb2\n"},
+			conflicts: []string{"b.txt"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo, dir := newTestRepo(t, test.files)
+
+			applyErr := ApplyPatch(repo, []byte(test.patch))
+			switch {
+			case test.conflicts != nil:
+				var conflictErr *PatchConflictError
+				if !errors.As(applyErr, &conflictErr) || strings.Join(conflictErr.Files, ",") != strings.Join(test.conflicts, ",") {
+					t.Fatalf("Expected a conflict on files %v, got %v", test.conflicts, applyErr)
+				}
+			case test.errText != "":
+				if applyErr == nil || !strings.Contains(applyErr.Error(), test.errText) {
+					t.Fatalf("Expected an error containing %q, got %v", test.errText, applyErr)
+				}
+			case applyErr != nil:
+				t.Fatal(applyErr)
+			}
+
+			for file, expected := range test.expected {
+				content, readErr := os.ReadFile(filepath.Join(dir, file))
+				if readErr != nil || string(content) != expected {
+					t.Fatalf("Expected file %s to contain %q, got %q (%v)", file, expected, content, readErr)
+				}
+			}
+			for _, file := range test.deleted {
+				if _, statErr := os.Stat(filepath.Join(dir, file)); !os.IsNotExist(statErr) {
+					t.Fatalf("Expected file %s to be deleted, got %v", file, statErr)
+				}
+			}
+		})
+	}
+}
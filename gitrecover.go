@@ -0,0 +1,121 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	billy "github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+var inProgressMarkers = []string{
+	"MERGE_HEAD",
+	"MERGE_MSG",
+	"MERGE_MODE",
+	"CHERRY_PICK_HEAD",
+	"REVERT_HEAD",
+	"rebase-merge",
+	"rebase-apply",
+}
+
+func readGitDirFile(fs billy.Filesystem, filePath string) (string, bool, error) {
+	fReader, openErr := fs.Open(filePath)
+	if openErr != nil {
+		if os.IsNotExist(openErr) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("Error opening file %s of git directory: %w", filePath, openErr)
+	}
+	defer fReader.Close()
+
+	content, readErr := ioutil.ReadAll(fReader)
+	if readErr != nil {
+		return "", false, fmt.Errorf("Error reading file %s of git directory: %w", filePath, readErr)
+	}
+
+	return strings.TrimSpace(string(content)), true, nil
+}
+
+func restoreRebasedBranch(repo *GitRepository, fs billy.Filesystem) error {
+	for _, rebaseDir := range []string{"rebase-merge", "rebase-apply"} {
+		headName, hasHeadName, headNameErr := readGitDirFile(fs, path.Join(rebaseDir, "head-name"))
+		if headNameErr != nil {
+			return headNameErr
+		}
+
+		origHead, hasOrigHead, origHeadErr := readGitDirFile(fs, path.Join(rebaseDir, "orig-head"))
+		if origHeadErr != nil {
+			return origHeadErr
+		}
+
+		if !hasHeadName || !hasOrigHead || !strings.HasPrefix(headName, "refs/heads/") {
+			continue
+		}
+
+		branchRef := plumbing.ReferenceName(headName)
+		setErr := repo.Repo.Storer.SetReference(plumbing.NewHashReference(branchRef, plumbing.NewHash(origHead)))
+		if setErr != nil {
+			return fmt.Errorf("Error restoring branch \"%s\" to commit %s: %w", branchRef.Short(), origHead, setErr)
+		}
+
+		setErr = repo.Repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, branchRef))
+		if setErr != nil {
+			return fmt.Errorf("Error pointing HEAD back to branch \"%s\": %w", branchRef.Short(), setErr)
+		}
+
+		logger.Printf("Restored branch \"%s\" to commit %s it was at before the interrupted rebase", branchRef.Short(), origHead)
+		return nil
+	}
+
+	return nil
+}
+
+/*
+Recovers a git repository on the filesystem that was left in the middle of a merge, rebase, cherry-pick or revert (ex: because the process was killed).
+The markers of the operation in progress are removed, the branch being rebased (if any) is restored to the commit it was at before the rebase
+and the worktree is hard reset to HEAD, discarding uncommitted changes. It is a no-op on the markers for repositories in memory, which never have any.
+*/
+func AbortInProgress(repo *GitRepository) error {
+	fsStorer, isFsStorer := repo.Repo.Storer.(interface{ Filesystem() billy.Filesystem })
+	if isFsStorer {
+		fs := fsStorer.Filesystem()
+
+		restoreErr := restoreRebasedBranch(repo, fs)
+		if restoreErr != nil {
+			return restoreErr
+		}
+
+		for _, marker := range inProgressMarkers {
+			removeErr := util.RemoveAll(fs, marker)
+			if removeErr != nil && !os.IsNotExist(removeErr) {
+				return fmt.Errorf("Error removing %s from git directory: %w", marker, removeErr)
+			}
+		}
+	}
+
+	head, headErr := repo.Repo.Head()
+	if headErr != nil {
+		return fmt.Errorf("Error accessing repo head: %w", headErr)
+	}
+
+	w, wErr := repo.Repo.Worktree()
+	if wErr != nil {
+		return fmt.Errorf("Error accessing repo worktree: %w", wErr)
+	}
+
+	resetErr := w.Reset(&gogit.ResetOptions{
+		Commit: head.Hash(),
+		Mode:   gogit.HardReset,
+	})
+	if resetErr != nil {
+		return fmt.Errorf("Error resetting worktree to commit %s: %w", head.Hash(), resetErr)
+	}
+
+	repo.recordOperation(CheckoutOperation, fmt.Sprintf("Aborted operations in progress and reset worktree to commit %s", head.Hash()))
+	return nil
+}
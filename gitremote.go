@@ -0,0 +1,251 @@
+package git
+
+import (
+	"fmt"
+	neturl "net/url"
+	"sort"
+	"strings"
+
+	gogit "github.com/go-git/go-git/v5"
+	gogitconf "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func listRemoteRefs(url string, creds Credentials) ([]*plumbing.Reference, error) {
+	remote := gogit.NewRemote(memory.NewStorage(), &gogitconf.RemoteConfig{
+		Name: "origin",
+		URLs: []string{url},
+	})
+
+	refs, listErr := remote.List(&gogit.ListOptions{
+		Auth: creds.AuthMethod(),
+	})
+	if listErr != nil {
+		return nil, fmt.Errorf("Error listing references of repo \"%s\": %w", url, listErr)
+	}
+
+	return refs, nil
+}
+
+/*
+Returns all the references of a remote repository with the hashes they point to, like "git ls-remote", without cloning it.
+The keys are the full names of the references (ex: "refs/heads/main" or "refs/tags/v1.0.0"). Symbolic references, like HEAD, are resolved to the hash of their target.
+*/
+func ListRemoteRefs(url string, creds Credentials) (map[string]string, error) {
+	refs, listErr := listRemoteRefs(url, creds)
+	if listErr != nil {
+		return nil, listErr
+	}
+
+	hashes := map[plumbing.ReferenceName]plumbing.Hash{}
+	for _, ref := range refs {
+		if ref.Type() == plumbing.HashReference {
+			hashes[ref.Name()] = ref.Hash()
+		}
+	}
+
+	result := map[string]string{}
+	for _, ref := range refs {
+		if ref.Type() == plumbing.HashReference {
+			result[ref.Name().String()] = ref.Hash().String()
+		} else if hash, ok := hashes[ref.Target()]; ok {
+			result[ref.Name().String()] = hash.String()
+		}
+	}
+
+	return result, nil
+}
+
+/*
+Returns the name of the default branch of a remote repository (ie, the branch its HEAD points to), without cloning it.
+*/
+func GetRemoteDefaultBranch(url string, creds Credentials) (string, error) {
+	refs, listErr := listRemoteRefs(url, creds)
+	if listErr != nil {
+		return "", listErr
+	}
+
+	for _, ref := range refs {
+		if ref.Name() != plumbing.HEAD {
+			continue
+		}
+
+		if ref.Type() != plumbing.SymbolicReference || !ref.Target().IsBranch() {
+			return "", fmt.Errorf("HEAD of repo \"%s\" doesn't point to a branch", url)
+		}
+
+		return ref.Target().Short(), nil
+	}
+
+	return "", fmt.Errorf("Repo \"%s\" doesn't advertise its HEAD", url)
+}
+
+/*
+Fetches the given refspecs (ex: "+refs/heads/main:refs/remotes/origin/main" or "+refs/tags/*:refs/tags/*") from origin into the git repository.
+This is meant for mirrors that track a custom set of references rather than the single branch synced by SyncGitRepo.
+The worktree is not updated.
+*/
+func FetchRefSpecs(repo *GitRepository, refSpecs []string, creds Credentials) error {
+	specs := []gogitconf.RefSpec{}
+	for _, refSpec := range refSpecs {
+		spec := gogitconf.RefSpec(refSpec)
+		validateErr := spec.Validate()
+		if validateErr != nil {
+			return fmt.Errorf("Invalid refspec \"%s\": %w", refSpec, validateErr)
+		}
+
+		specs = append(specs, spec)
+	}
+
+	fetchErr := repo.Repo.Fetch(&gogit.FetchOptions{
+		Auth:       creds.AuthMethod(),
+		RemoteName: "origin",
+		RefSpecs:   specs,
+		Tags:       gogit.NoTags,
+		Force:      false,
+	})
+	if fetchErr != nil {
+		if fetchErr.Error() == gogit.NoErrAlreadyUpToDate.Error() {
+			logger.Printf("Fetch operation was no-op as local references were already up to date.")
+			return nil
+		}
+
+		return fmt.Errorf("Error fetching refspecs: %w", fetchErr)
+	}
+
+	logger.Printf("Fetched %d refspecs from origin", len(specs))
+	repo.recordOperation(FetchOperation, fmt.Sprintf("Fetched refspecs %s from origin", strings.Join(refSpecs, ", ")))
+	return nil
+}
+
+func parseRemoteURL(url string) (string, string, error) {
+	if strings.Contains(url, "://") {
+		parsed, parseErr := neturl.Parse(url)
+		if parseErr != nil {
+			return "", "", fmt.Errorf("Error parsing remote url \"%s\": %w", url, parseErr)
+		}
+
+		if parsed.Scheme != "ssh" && parsed.Scheme != "https" && parsed.Scheme != "http" {
+			return "", "", fmt.Errorf("Remote url \"%s\" has unsupported scheme \"%s\"", url, parsed.Scheme)
+		}
+
+		return parsed.Hostname(), strings.TrimPrefix(parsed.Path, "/"), nil
+	}
+
+	//scp-like syntax (ex: git@github.com:org/repo.git)
+	colonIdx := strings.Index(url, ":")
+	if colonIdx == -1 {
+		return "", "", fmt.Errorf("Remote url \"%s\" is neither an ssh nor an https url", url)
+	}
+
+	host := url[:colonIdx]
+	if atIdx := strings.LastIndex(host, "@"); atIdx != -1 {
+		host = host[atIdx+1:]
+	}
+
+	return host, strings.TrimPrefix(url[colonIdx+1:], "/"), nil
+}
+
+/*
+Converts a remote url between the ssh and https schemes, for example from "git@github.com:org/repo.git" to "https://github.com/org/repo.git" and back.
+The target scheme is either "ssh", which produces an url in the scp-like syntax with the "git" user, or "https".
+Non-standard ports and credentials of the original url are not carried over as they rarely apply to the other scheme.
+*/
+func ConvertRemoteURL(url string, toScheme string) (string, error) {
+	host, repoPath, parseErr := parseRemoteURL(url)
+	if parseErr != nil {
+		return "", parseErr
+	}
+
+	if host == "" || repoPath == "" {
+		return "", fmt.Errorf("Remote url \"%s\" is missing its host or repository path", url)
+	}
+
+	switch toScheme {
+	case "ssh":
+		return fmt.Sprintf("git@%s:%s", host, repoPath), nil
+	case "https":
+		return fmt.Sprintf("https://%s/%s", host, repoPath), nil
+	default:
+		return "", fmt.Errorf("Unsupported target scheme \"%s\": it should be either \"ssh\" or \"https\"", toScheme)
+	}
+}
+
+/*
+Fetches the given branch from origin without merging it and checks whether pulling it would conflict with the local state of the git repository.
+A conflict is reported for each file that changed on origin since the last common commit and that was also changed locally,
+either by local commits or by uncommitted changes (including untracked files). Returns whether there are conflicts and the conflicting paths, sorted.
+Note that if the local branch has commits that are not on origin, the pull will fail as non-fast-forward even if no file conflicts.
+*/
+func PullWouldConflict(repo *GitRepository, ref string, creds Credentials) (bool, []string, error) {
+	remoteRef := plumbing.NewRemoteReferenceName("origin", ref)
+	fetchErr := repo.Repo.Fetch(&gogit.FetchOptions{
+		Auth:       creds.AuthMethod(),
+		RemoteName: "origin",
+		RefSpecs:   []gogitconf.RefSpec{gogitconf.RefSpec(fmt.Sprintf("+%s:%s", plumbing.NewBranchReferenceName(ref), remoteRef))},
+		Tags:       gogit.NoTags,
+	})
+	if fetchErr != nil && fetchErr.Error() != gogit.NoErrAlreadyUpToDate.Error() {
+		return false, nil, fmt.Errorf("Error fetching branch \"%s\": %w", ref, fetchErr)
+	}
+
+	remote, remoteErr := resolveCommit(repo, remoteRef.String())
+	if remoteErr != nil {
+		return false, nil, remoteErr
+	}
+
+	head, headErr := getHeadCommit(repo)
+	if headErr != nil {
+		return false, nil, headErr
+	}
+
+	if head.Hash == remote.Hash {
+		return false, []string{}, nil
+	}
+
+	bases, baseErr := head.MergeBase(remote)
+	if baseErr != nil {
+		return false, nil, fmt.Errorf("Error finding common ancestor of commits \"%s\" and \"%s\": %w", head.Hash, remote.Hash, baseErr)
+	}
+	if len(bases) == 0 {
+		return false, nil, fmt.Errorf("Local branch and branch \"%s\" on origin have no common history", ref)
+	}
+	base := bases[0]
+
+	remoteChanges, remoteChangesErr := getChangedPaths(base, remote)
+	if remoteChangesErr != nil {
+		return false, nil, remoteChangesErr
+	}
+
+	localChanges, localChangesErr := getChangedPaths(base, head)
+	if localChangesErr != nil {
+		return false, nil, localChangesErr
+	}
+
+	w, wErr := repo.Repo.Worktree()
+	if wErr != nil {
+		return false, nil, fmt.Errorf("Error accessing repo worktree: %w", wErr)
+	}
+
+	stat, statErr := w.Status()
+	if statErr != nil {
+		return false, nil, fmt.Errorf("Error getting repo status: %w", statErr)
+	}
+
+	for file, fileStat := range stat {
+		if fileStat.Worktree != gogit.Unmodified || fileStat.Staging != gogit.Unmodified {
+			localChanges[file] = true
+		}
+	}
+
+	conflicts := []string{}
+	for file := range remoteChanges {
+		if localChanges[file] {
+			conflicts = append(conflicts, file)
+		}
+	}
+	sort.Strings(conflicts)
+
+	return len(conflicts) > 0, conflicts, nil
+}
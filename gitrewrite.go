@@ -0,0 +1,200 @@
+package git
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+func signCommit(commit *object.Commit, key *CommitSignatureKey, hash crypto.Hash) error {
+	var config *packet.Config
+	if hash != 0 {
+		if !hash.Available() {
+			return fmt.Errorf("Signature hash algorithm %s is not available", hash.String())
+		}
+
+		config = &packet.Config{DefaultHash: hash}
+	}
+
+	payload, payloadErr := getCommitSignedPayload(commit)
+	if payloadErr != nil {
+		return payloadErr
+	}
+
+	var signature bytes.Buffer
+	signErr := openpgp.ArmoredDetachSign(&signature, key.Entity, bytes.NewReader(payload), config)
+	if signErr != nil {
+		return fmt.Errorf("Error signing commit: %w", signErr)
+	}
+
+	commit.PGPSignature = signature.String()
+	return nil
+}
+
+func storeCommit(repo *GitRepository, commit *object.Commit) (plumbing.Hash, error) {
+	obj := repo.Repo.Storer.NewEncodedObject()
+	encErr := commit.Encode(obj)
+	if encErr != nil {
+		return plumbing.ZeroHash, fmt.Errorf("Error encoding commit: %w", encErr)
+	}
+
+	hash, storeErr := repo.Repo.Storer.SetEncodedObject(obj)
+	if storeErr != nil {
+		return plumbing.ZeroHash, fmt.Errorf("Error storing commit: %w", storeErr)
+	}
+
+	return hash, nil
+}
+
+func moveHead(repo *GitRepository, hash plumbing.Hash) error {
+	head, headErr := repo.Repo.Storer.Reference(plumbing.HEAD)
+	if headErr != nil {
+		return fmt.Errorf("Error accessing repo head: %w", headErr)
+	}
+
+	//If HEAD points to a branch, the branch is moved (even if it has no commit yet), else the detached HEAD is
+	name := plumbing.HEAD
+	if head.Type() == plumbing.SymbolicReference {
+		name = head.Target()
+	}
+
+	setErr := repo.Repo.Storer.SetReference(plumbing.NewHashReference(name, hash))
+	if setErr != nil {
+		return fmt.Errorf("Error updating reference \"%s\" to commit %s: %w", name, hash, setErr)
+	}
+
+	return nil
+}
+
+/*
+Rewrites the top commit of the git repository with the author and committer resolved from the commit options (see CommitFiles),
+keeping its content, parents and message. If a signature key is passed in the options, the rewritten commit is signed with it, otherwise it is left unsigned.
+Unless the PreserveDates option is set, the dates of the rewritten commit are set to the current time.
+The rewritten commit replaces the original one on the current branch and can then be pushed with the Lease push option.
+*/
+func ReauthorTopCommit(repo *GitRepository, opts CommitOptions) error {
+	commit, commitErr := getHeadCommit(repo)
+	if commitErr != nil {
+		return commitErr
+	}
+
+	author, committer, authorErr := resolveAuthorAndCommitter(repo, opts)
+	if authorErr != nil {
+		return authorErr
+	}
+
+	if opts.PreserveDates {
+		author.When = commit.Author.When
+		committer.When = commit.Committer.When
+	}
+
+	rewritten := &object.Commit{
+		Author:       *author,
+		Committer:    *committer,
+		Message:      commit.Message,
+		TreeHash:     commit.TreeHash,
+		ParentHashes: commit.ParentHashes,
+	}
+
+	if opts.SignatureKey != nil {
+		signErr := signCommit(rewritten, opts.SignatureKey, opts.SignatureHash)
+		if signErr != nil {
+			return signErr
+		}
+	}
+
+	hash, storeErr := storeCommit(repo, rewritten)
+	if storeErr != nil {
+		return storeErr
+	}
+
+	moveErr := moveHead(repo, hash)
+	if moveErr != nil {
+		return moveErr
+	}
+
+	logger.Printf("Rewrote top commit \"%s\" as \"%s\" authored by \"%s <%s>\"", commit.Hash, hash, author.Name, author.Email)
+	repo.recordOperation(RewriteOperation, fmt.Sprintf("Rewrote top commit \"%s\" with author \"%s <%s>\"", commit.Hash, author.Name, author.Email))
+	return nil
+}
+
+/*
+Replaces the given number of commits at the top of the current branch of the git repository by a single commit with the given message
+and the content of the top commit, like a soft reset to HEAD~count followed by a commit. The author and signature of the new commit are determined by the commit options as in CommitFiles.
+Returns an error if the branch doesn't have that many commits or if any of the squashed commits is a merge commit.
+*/
+func SquashCommits(repo *GitRepository, count int, msg string, opts CommitOptions) error {
+	if count < 1 {
+		return fmt.Errorf("Cannot squash %d commits: at least one commit must be squashed", count)
+	}
+
+	head, headErr := getHeadCommit(repo)
+	if headErr != nil {
+		return headErr
+	}
+
+	commit := head
+	parentHashes := []plumbing.Hash{}
+	for idx := 0; idx < count; idx++ {
+		if commit.NumParents() > 1 {
+			return fmt.Errorf("Cannot squash commit \"%s\" as it is a merge commit", commit.Hash)
+		}
+
+		if commit.NumParents() == 0 {
+			if idx < count-1 {
+				return fmt.Errorf("Cannot squash %d commits as the branch only has %d", count, idx+1)
+			}
+			break
+		}
+
+		if idx == count-1 {
+			parentHashes = []plumbing.Hash{commit.ParentHashes[0]}
+			break
+		}
+
+		parent, parentErr := commit.Parent(0)
+		if parentErr != nil {
+			return fmt.Errorf("Error accessing parent of commit \"%s\": %w", commit.Hash, parentErr)
+		}
+		commit = parent
+	}
+
+	author, committer, authorErr := resolveAuthorAndCommitter(repo, opts)
+	if authorErr != nil {
+		return authorErr
+	}
+
+	squashed := &object.Commit{
+		Author:       *author,
+		Committer:    *committer,
+		Message:      msg,
+		TreeHash:     head.TreeHash,
+		ParentHashes: parentHashes,
+	}
+
+	if opts.SignatureKey != nil {
+		signErr := signCommit(squashed, opts.SignatureKey, opts.SignatureHash)
+		if signErr != nil {
+			return signErr
+		}
+	}
+
+	hash, storeErr := storeCommit(repo, squashed)
+	if storeErr != nil {
+		return storeErr
+	}
+
+	moveErr := moveHead(repo, hash)
+	if moveErr != nil {
+		return moveErr
+	}
+
+	logger.Printf("Squashed %d commits into commit \"%s\" with message \"%s\"", count, hash, msg)
+	repo.recordOperation(RewriteOperation, fmt.Sprintf("Squashed %d commits with message \"%s\"", count, msg))
+	return nil
+}
@@ -0,0 +1,132 @@
+package git
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+//Returns a repository with three commits, adding a.txt, b.txt and c.txt in that order
+func newTestHistoryRepo(t *testing.T) (*GitRepository, []*object.Commit) {
+	t.Helper()
+
+	repo, dir := newTestRepo(t, map[string]string{"a.txt": "a\n"})
+	commits := []*object.Commit{getTestHeadCommit(t, repo)}
+	for _, file := range []string{"b.txt", "c.txt"} {
+		paths := writeTestFiles(t, dir, map[string]string{file: file + "\n"})
+		if _, err := CommitFiles(repo, paths, "Add "+file, testCommitOptions); err != nil {
+			t.Fatal(err)
+		}
+		commits = append(commits, getTestHeadCommit(t, repo))
+	}
+
+	return repo, commits
+}
+
+func TestReauthorTopCommit(t *testing.T) {
+	repo, commits := newTestHistoryRepo(t)
+	original := commits[2]
+
+	key := newTestSignatureKey(t, "New Author", "new@example.com")
+	opts := CommitOptions{Name: "New Author", Email: "new@example.com", SignatureKey: key, PreserveDates: true}
+	if err := ReauthorTopCommit(repo, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	rewritten := getTestHeadCommit(t, repo)
+	if rewritten.Hash == original.Hash {
+		t.Fatal("Expected the top commit to be rewritten")
+	}
+	if rewritten.TreeHash != original.TreeHash || rewritten.Message != original.Message {
+		t.Fatalf("Expected the tree and message of the top commit to be kept, got tree %s and message %q", rewritten.TreeHash, rewritten.Message)
+	}
+	if len(rewritten.ParentHashes) != 1 || rewritten.ParentHashes[0] != commits[1].Hash {
+		t.Fatalf("Expected the parent of the top commit to be kept, got %v", rewritten.ParentHashes)
+	}
+	if rewritten.Author.Name != "New Author" || rewritten.Author.Email != "new@example.com" || rewritten.Committer.Email != "new@example.com" {
+		t.Fatalf("Expected the commit to be authored by the new author, got %s and %s", rewritten.Author.String(), rewritten.Committer.String())
+	}
+	if !rewritten.Author.When.Equal(original.Author.When) {
+		t.Fatalf("Expected the author date %s to be preserved, got %s", original.Author.When, rewritten.Author.When)
+	}
+
+	if err := VerifyTopCommit(repo, []string{getTestArmoredPublicKey(t, key)}); err != nil {
+		t.Fatalf("Expected the rewritten commit to be signed with the new key: %v", err)
+	}
+}
+
+func TestSquashCommits(t *testing.T) {
+	repo, commits := newTestHistoryRepo(t)
+
+	before := time.Now().Add(-time.Second)
+	if err := SquashCommits(repo, 2, "Add b.txt and c.txt", CommitOptions{Name: "Squasher", Email: "squasher@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	squashed := getTestHeadCommit(t, repo)
+	if squashed.TreeHash != commits[2].TreeHash {
+		t.Fatalf("Expected the tree of the previous top commit, got %s", squashed.TreeHash)
+	}
+	if len(squashed.ParentHashes) != 1 || squashed.ParentHashes[0] != commits[0].Hash {
+		t.Fatalf("Expected the squashed commit to have the initial commit as parent, got %v", squashed.ParentHashes)
+	}
+	if squashed.Message != "Add b.txt and c.txt" {
+		t.Fatalf("Expected the given message, got %q", squashed.Message)
+	}
+	if squashed.Author.Name != "Squasher" || squashed.Author.Email != "squasher@example.com" || squashed.Author.When.Before(before) {
+		t.Fatalf("Expected the squashed commit to be authored now by the given author, got %s", squashed.Author.String())
+	}
+}
+
+func TestSquashCommitsUpToRootCommit(t *testing.T) {
+	repo, commits := newTestHistoryRepo(t)
+
+	if err := SquashCommits(repo, 3, "Add all files", testCommitOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	squashed := getTestHeadCommit(t, repo)
+	if squashed.TreeHash != commits[2].TreeHash || len(squashed.ParentHashes) != 0 {
+		t.Fatalf("Expected a root commit with the tree of the previous top commit, got tree %s and parents %v", squashed.TreeHash, squashed.ParentHashes)
+	}
+}
+
+func TestSquashCommitsErrorsLeaveHead(t *testing.T) {
+	repo, commits := newTestHistoryRepo(t)
+
+	//A merge of the top commit with the initial commit is added on top of the branch
+	merge := storeTestObject(t, repo, &object.Commit{
+		Author:       object.Signature{Name: "Test Author", Email: "author@example.com", When: time.Now()},
+		Committer:    object.Signature{Name: "Test Author", Email: "author@example.com", When: time.Now()},
+		Message:      "Merge",
+		TreeHash:     commits[2].TreeHash,
+		ParentHashes: []plumbing.Hash{commits[2].Hash, commits[0].Hash},
+	})
+	if err := moveHead(repo, merge); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, count := range []int{0, -1, 1, 2} {
+		if err := SquashCommits(repo, count, "Squashed", testCommitOptions); err == nil {
+			t.Fatalf("Expected an error squashing %d commits", count)
+		}
+
+		if head := getTestHeadCommit(t, repo); head.Hash != merge {
+			t.Fatalf("Expected HEAD to be left at commit %s after squashing %d commits, got %s", merge, count, head.Hash)
+		}
+	}
+}
+
+func TestSquashCommitsBeyondRootCommit(t *testing.T) {
+	repo, commits := newTestHistoryRepo(t)
+
+	if err := SquashCommits(repo, 4, "Squashed", testCommitOptions); err == nil {
+		t.Fatal("Expected an error squashing more commits than the branch has")
+	}
+
+	if head := getTestHeadCommit(t, repo); head.Hash != commits[2].Hash {
+		t.Fatalf("Expected HEAD to be left at commit %s, got %s", commits[2].Hash, head.Hash)
+	}
+}
@@ -0,0 +1,149 @@
+package git
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+)
+
+const largestBlobsCount = 10
+
+/*
+Size of a blob of the repository along with the first path it was found at
+*/
+type BlobSize struct {
+	Hash string
+	Path string
+	Size int64
+}
+
+/*
+Count and total size in bytes of the objects of each type reachable from the top commit of a repository, as returned by GetRepoSizeStats.
+Sizes are the uncompressed sizes of the objects, not the space they take on disk.
+*/
+type RepoSizeStats struct {
+	Commits      int
+	CommitsSize  int64
+	Trees        int
+	TreesSize    int64
+	Blobs        int
+	BlobsSize    int64
+	//Largest blobs of the repository, from the largest to the smallest
+	LargestBlobs []BlobSize
+}
+
+type sizeWalker struct {
+	repo    *GitRepository
+	stats   RepoSizeStats
+	visited map[plumbing.Hash]bool
+	blobs   []BlobSize
+}
+
+func (w *sizeWalker) objectSize(hash plumbing.Hash) (int64, error) {
+	size, sizeErr := w.repo.Repo.Storer.EncodedObjectSize(hash)
+	if sizeErr != nil {
+		return 0, fmt.Errorf("Error accessing size of object \"%s\": %w", hash, sizeErr)
+	}
+
+	return size, nil
+}
+
+func (w *sizeWalker) walkTree(hash plumbing.Hash, dir string) error {
+	if w.visited[hash] {
+		return nil
+	}
+	w.visited[hash] = true
+
+	size, sizeErr := w.objectSize(hash)
+	if sizeErr != nil {
+		return sizeErr
+	}
+	w.stats.Trees++
+	w.stats.TreesSize += size
+
+	tree, treeErr := w.repo.Repo.TreeObject(hash)
+	if treeErr != nil {
+		return fmt.Errorf("Error accessing tree \"%s\": %w", hash, treeErr)
+	}
+
+	for _, entry := range tree.Entries {
+		entryPath := path.Join(dir, entry.Name)
+
+		if entry.Mode == filemode.Dir {
+			walkErr := w.walkTree(entry.Hash, entryPath)
+			if walkErr != nil {
+				return walkErr
+			}
+			continue
+		}
+
+		//Submodules reference commits of other repositories
+		if entry.Mode == filemode.Submodule || w.visited[entry.Hash] {
+			continue
+		}
+		w.visited[entry.Hash] = true
+
+		blobSize, blobSizeErr := w.objectSize(entry.Hash)
+		if blobSizeErr != nil {
+			return blobSizeErr
+		}
+		w.stats.Blobs++
+		w.stats.BlobsSize += blobSize
+		w.blobs = append(w.blobs, BlobSize{Hash: entry.Hash.String(), Path: entryPath, Size: blobSize})
+	}
+
+	return nil
+}
+
+/*
+Returns the count and total size of the commits, trees and blobs reachable from the top commit of the git repository, along with its largest blobs.
+This is useful to plan storage or to detect large binaries that were committed by mistake. In shallow clones, only the available history is accounted for.
+*/
+func GetRepoSizeStats(repo *GitRepository) (RepoSizeStats, error) {
+	head, headErr := repo.Repo.Head()
+	if headErr != nil {
+		return RepoSizeStats{}, fmt.Errorf("Error accessing repo head: %w", headErr)
+	}
+
+	commitHashes := []plumbing.Hash{}
+	walkErr := walkCommitHashes(repo, head.Hash(), func(hash plumbing.Hash) bool {
+		commitHashes = append(commitHashes, hash)
+		return true
+	})
+	if walkErr != nil {
+		return RepoSizeStats{}, walkErr
+	}
+
+	walker := sizeWalker{repo: repo, visited: map[plumbing.Hash]bool{}}
+	for _, hash := range commitHashes {
+		size, sizeErr := walker.objectSize(hash)
+		if sizeErr != nil {
+			return RepoSizeStats{}, sizeErr
+		}
+		walker.stats.Commits++
+		walker.stats.CommitsSize += size
+
+		commit, commitErr := repo.Repo.CommitObject(hash)
+		if commitErr != nil {
+			return RepoSizeStats{}, fmt.Errorf("Error accessing commit \"%s\": %w", hash, commitErr)
+		}
+
+		treeErr := walker.walkTree(commit.TreeHash, "")
+		if treeErr != nil {
+			return RepoSizeStats{}, treeErr
+		}
+	}
+
+	sort.SliceStable(walker.blobs, func(i, j int) bool {
+		return walker.blobs[i].Size > walker.blobs[j].Size
+	})
+	if len(walker.blobs) > largestBlobsCount {
+		walker.blobs = walker.blobs[:largestBlobsCount]
+	}
+	walker.stats.LargestBlobs = walker.blobs
+
+	return walker.stats, nil
+}
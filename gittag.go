@@ -0,0 +1,107 @@
+package git
+
+import (
+	"fmt"
+
+	gogit "github.com/go-git/go-git/v5"
+	gogitconf "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+/*
+Parameters of a tag to create
+*/
+type TagOptions struct {
+	//Name of the tag
+	Name         string
+	//Optional message of the tag. If set, an annotated tag is created, otherwise a lightweight one is
+	Message      string
+	//Optional key used to sign the annotated tag
+	SignatureKey *CommitSignatureKey
+}
+
+func createTag(repo *GitRepository, hash plumbing.Hash, opts *TagOptions, taggerOpts CommitOptions) (*plumbing.Reference, error) {
+	var createOpts *gogit.CreateTagOptions
+	if opts.Message != "" {
+		//The tagger is the committer, so that a tag created along with a commit has the same identity as the commit
+		_, tagger, taggerErr := resolveAuthorAndCommitter(repo, taggerOpts)
+		if taggerErr != nil {
+			return nil, taggerErr
+		}
+
+		createOpts = &gogit.CreateTagOptions{
+			Tagger:  tagger,
+			Message: opts.Message,
+		}
+		if opts.SignatureKey != nil {
+			createOpts.SignKey = opts.SignatureKey.Entity
+		}
+	} else if opts.SignatureKey != nil {
+		return nil, fmt.Errorf("Tag \"%s\" cannot be signed as it has no message", opts.Name)
+	}
+
+	tag, tagErr := repo.Repo.CreateTag(opts.Name, hash, createOpts)
+	if tagErr != nil {
+		return nil, fmt.Errorf("Error creating tag \"%s\" on commit %s: %w", opts.Name, hash, tagErr)
+	}
+
+	return tag, nil
+}
+
+func tagAndPush(repo *GitRepository, hash plumbing.Hash, opts *TagOptions, creds Credentials) error {
+	_, tagErr := createTag(repo, hash, opts, CommitOptions{})
+	if tagErr != nil {
+		return tagErr
+	}
+
+	tagRef := plumbing.NewTagReferenceName(opts.Name)
+	pushErr := repo.Repo.Push(&gogit.PushOptions{
+		Auth:       creds.AuthMethod(),
+		Force:      false,
+		Prune:      false,
+		RemoteName: "origin",
+		RefSpecs:   []gogitconf.RefSpec{gogitconf.RefSpec(fmt.Sprintf("%s:%s", tagRef, tagRef))},
+	})
+	if pushErr != nil && pushErr.Error() != gogit.NoErrAlreadyUpToDate.Error() {
+		return fmt.Errorf("Error pushing tag \"%s\": %w", opts.Name, pushErr)
+	}
+
+	logger.Printf("Tagged commit %s as \"%s\" and pushed the tag", hash, opts.Name)
+	repo.recordOperation(PushOperation, fmt.Sprintf("Pushed tag \"%s\" on commit %s to origin", opts.Name, hash))
+	return nil
+}
+
+/*
+Commits the given files signed with the given key and creates an annotated tag on the commit, signed with the same key, as a single release operation.
+The tagger is the committer of the commit. Returns the hash of the commit and the hash of the tag object.
+Nothing is pushed and an error is returned if there are no changes to commit, as a release needs a commit of its own.
+*/
+func ReleaseCommit(repo *GitRepository, files []string, commitMsg string, tagName string, tagMsg string, key *CommitSignatureKey, opts CommitOptions) (string, string, error) {
+	if key == nil {
+		return "", "", fmt.Errorf("A signature key is required to release tag \"%s\"", tagName)
+	}
+
+	if tagMsg == "" {
+		return "", "", fmt.Errorf("Release tag \"%s\" cannot be signed as it has no message", tagName)
+	}
+
+	opts.SignatureKey = key
+	opts.RequireChanges = true
+	_, commitErr := CommitFiles(repo, files, commitMsg, opts)
+	if commitErr != nil {
+		return "", "", commitErr
+	}
+
+	head, headErr := repo.Repo.Head()
+	if headErr != nil {
+		return "", "", fmt.Errorf("Error accessing repo head: %w", headErr)
+	}
+
+	tag, tagErr := createTag(repo, head.Hash(), &TagOptions{Name: tagName, Message: tagMsg, SignatureKey: key}, opts)
+	if tagErr != nil {
+		return "", "", tagErr
+	}
+
+	logger.Printf("Released commit %s with signed tag \"%s\"", head.Hash(), tagName)
+	return head.Hash().String(), tag.Hash().String(), nil
+}
@@ -0,0 +1,46 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func TestReleaseCommitSignsCommitAndTag(t *testing.T) {
+	repo, dir := newTestRepo(t, map[string]string{"a.txt": "a\n"})
+	key := newTestSignatureKey(t, "Release Bot", "release@example.com")
+	armored := getTestArmoredPublicKey(t, key)
+
+	paths := writeTestFiles(t, dir, map[string]string{"VERSION": "1.0.0\n"})
+	opts := CommitOptions{CommitterName: "Release Bot", CommitterEmail: "release@example.com"}
+	commitHash, tagHash, releaseErr := ReleaseCommit(repo, paths, "Release 1.0.0", "v1.0.0", "Version 1.0.0", key, opts)
+	if releaseErr != nil {
+		t.Fatal(releaseErr)
+	}
+
+	commit := getTestHeadCommit(t, repo)
+	if commit.Hash.String() != commitHash {
+		t.Fatalf("Expected the released commit %s to be checked out, got %s", commitHash, commit.Hash)
+	}
+	if err := VerifyTopCommit(repo, []string{armored}); err != nil {
+		t.Fatalf("Expected the released commit to be signed: %v", err)
+	}
+
+	tag, tagErr := repo.Repo.TagObject(plumbing.NewHash(tagHash))
+	if tagErr != nil {
+		t.Fatal(tagErr)
+	}
+	if tag.Target.String() != commitHash || tag.Name != "v1.0.0" || tag.Message != "Version 1.0.0\n" {
+		t.Fatalf("Expected tag v1.0.0 on commit %s, got tag %s on %s with message %q", commitHash, tag.Name, tag.Target, tag.Message)
+	}
+	if _, err := tag.Verify(armored); err != nil {
+		t.Fatalf("Expected the release tag to be signed: %v", err)
+	}
+
+	if tag.Tagger.Name != commit.Committer.Name || tag.Tagger.Email != commit.Committer.Email || tag.Tagger.Email != "release@example.com" {
+		t.Fatalf("Expected the tagger to be the committer %s, got %s", commit.Committer.String(), tag.Tagger.String())
+	}
+	if commit.Author.Email != "release@example.com" {
+		t.Fatalf("Expected the committer to be used as the author, got %s", commit.Author.String())
+	}
+}
@@ -0,0 +1,71 @@
+package git
+
+import (
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func parseTrailerLine(line string) (string, string, bool) {
+	sepIdx := strings.Index(line, ":")
+	if sepIdx <= 0 {
+		return "", "", false
+	}
+
+	key := line[:sepIdx]
+	for _, char := range key {
+		isAlphaNum := (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9')
+		if !isAlphaNum && char != '-' {
+			return "", "", false
+		}
+	}
+
+	return key, strings.TrimSpace(line[sepIdx+1:]), true
+}
+
+/*
+Returns the trailers (ex: "Signed-off-by: Jane Doe <jane@example.com>") of the message of the given commit, keyed by trailer name.
+Like git, trailers are read from the last paragraph of the message, which must be separated from the subject by a blank line and only contain trailers.
+Trailer values spanning several lines (continuation lines start with a whitespace) are joined with a space.
+If the message has no trailers, an empty map is returned.
+*/
+func ParseCommitTrailers(commit *object.Commit) map[string][]string {
+	trailers := map[string][]string{}
+
+	paragraphs := strings.Split(strings.TrimSpace(strings.ReplaceAll(commit.Message, "\r\n", "\n")), "\n\n")
+	if len(paragraphs) < 2 {
+		return trailers
+	}
+
+	type trailer struct {
+		key   string
+		value string
+	}
+
+	parsed := []trailer{}
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			if len(parsed) == 0 {
+				return trailers
+			}
+			parsed[len(parsed)-1].value = parsed[len(parsed)-1].value + " " + strings.TrimSpace(line)
+			continue
+		}
+
+		key, value, ok := parseTrailerLine(line)
+		if !ok {
+			return trailers
+		}
+		parsed = append(parsed, trailer{key, value})
+	}
+
+	for _, t := range parsed {
+		trailers[t.key] = append(trailers[t.key], t.value)
+	}
+
+	return trailers
+}
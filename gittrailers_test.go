@@ -0,0 +1,71 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestParseCommitTrailers(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		expected map[string][]string
+	}{
+		{
+			name:    "trailers",
+			message: "Add feature\n\nLonger description.\n\nReviewed-by: Jane Doe <jane@example.com>\nRefs: #12\n",
+			expected: map[string][]string{
+				"Reviewed-by": {"Jane Doe <jane@example.com>"},
+				"Refs":        {"#12"},
+			},
+		},
+		{
+			name:    "continuation lines",
+			message: "Add feature\n\nSee-also: first part\n  second part\n\tthird part\nRefs: #12\n",
+			expected: map[string][]string{
+				"See-also": {"first part second part third part"},
+				"Refs":     {"#12"},
+			},
+		},
+		{
+			name:    "repeated keys",
+			message: "Add feature\n\nSigned-off-by: Jane Doe <jane@example.com>\nSigned-off-by: John Doe <john@example.com>\n",
+			expected: map[string][]string{
+				"Signed-off-by": {"Jane Doe <jane@example.com>", "John Doe <john@example.com>"},
+			},
+		},
+		{
+			name:     "trailer block not last",
+			message:  "Add feature\n\nRefs: #12\n\nClosing paragraph that is not a trailer.\n",
+			expected: map[string][]string{},
+		},
+		{
+			name:     "last paragraph mixing trailers and text",
+			message:  "Add feature\n\nRefs: #12\nThis is not a trailer\n",
+			expected: map[string][]string{},
+		},
+		{
+			name:     "subject only",
+			message:  "Refs: #12\n",
+			expected: map[string][]string{},
+		},
+		{
+			name:    "windows line endings",
+			message: "Add feature\r\n\r\nRefs: #12\r\n",
+			expected: map[string][]string{
+				"Refs": {"#12"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			trailers := ParseCommitTrailers(&object.Commit{Message: test.message})
+			if !reflect.DeepEqual(trailers, test.expected) {
+				t.Fatalf("Expected trailers %v, got %v", test.expected, trailers)
+			}
+		})
+	}
+}
@@ -0,0 +1,692 @@
+package git
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+/*
+Error returned, possibly wrapped, by the verification functions when a commit isn't signed at all
+*/
+var ErrUnsignedCommit = errors.New("The commit isn't signed.")
+
+/*
+Verifies commit signatures against a keyring of trusted keys that is parsed only once, when the verifier is created.
+It is meant to be reused across many verifications, for example in a long-running service.
+*/
+type Verifier struct {
+	keyring               openpgp.EntityList
+	keyringId             string
+	trust                 map[string]TrustLevel
+	//Optional cache of verification results. Caches can be shared between verifiers as results are keyed by keyring
+	Cache                 *VerificationCache
+	//If true, the validity of the signing key is evaluated at the author date of the commit instead of the current time.
+	//This allows historical commits signed with keys that have since expired to be verified, but should not be used for live enforcement.
+	AtCommitTime          bool
+	//If true, the author email of a validly signed commit must also match the email of one of the identities of the signing key.
+	//This prevents a trusted signer from impersonating another author in the commit metadata
+	RequireAuthorIdentity bool
+	//Minimum trust level the signing key of a commit must have for the commit to be verified. Keys below it are rejected even if they validate the signature.
+	//Trust levels are only attributed to keys by NewVerifierWithTrust, so the keys of other verifiers are all of unknown trust
+	MinTrust              TrustLevel
+}
+
+/*
+Level of trust attributed to a key of the keyring of a verifier, from the lowest to the highest
+*/
+type TrustLevel int
+
+const (
+	TrustUnknown TrustLevel = iota
+	TrustMarginal
+	TrustFull
+	TrustUltimate
+)
+
+func (t TrustLevel) String() string {
+	switch t {
+	case TrustMarginal:
+		return "marginal"
+	case TrustFull:
+		return "full"
+	case TrustUltimate:
+		return "ultimate"
+	default:
+		return "unknown"
+	}
+}
+
+/*
+Armored keyring along with the trust level attributed to all its keys
+*/
+type TrustedKeyring struct {
+	ArmoredKeyring string
+	Trust          TrustLevel
+}
+
+/*
+Returns a verifier trusting all the keys contained in the given armored keyrings.
+Returns an error if any of the keyrings cannot be parsed.
+*/
+func NewVerifier(armoredKeyrings []string) (*Verifier, error) {
+	keyring := openpgp.EntityList{}
+	for idx, armoredKeyring := range armoredKeyrings {
+		entities, readErr := openpgp.ReadArmoredKeyRing(strings.NewReader(armoredKeyring))
+		if readErr != nil {
+			return nil, fmt.Errorf("Error parsing trusted keyring at position %d: %w", idx, readErr)
+		}
+
+		keyring = append(keyring, entities...)
+	}
+
+	keyringId, keyringIdErr := getKeyringId(keyring, nil)
+	if keyringIdErr != nil {
+		return nil, keyringIdErr
+	}
+
+	return &Verifier{keyring: keyring, keyringId: keyringId}, nil
+}
+
+/*
+Returns a verifier trusting all the keys contained in the given armored keyrings, at the trust level of their keyring.
+If a key appears in several keyrings, its highest trust level is kept. Combined with the MinTrust property, it allows only some signers to be accepted.
+Returns an error if any of the keyrings cannot be parsed.
+*/
+func NewVerifierWithTrust(trustedKeyrings []TrustedKeyring) (*Verifier, error) {
+	keyring := openpgp.EntityList{}
+	trust := map[string]TrustLevel{}
+	for idx, trustedKeyring := range trustedKeyrings {
+		entities, readErr := openpgp.ReadArmoredKeyRing(strings.NewReader(trustedKeyring.ArmoredKeyring))
+		if readErr != nil {
+			return nil, fmt.Errorf("Error parsing trusted keyring at position %d: %w", idx, readErr)
+		}
+
+		for _, entity := range entities {
+			fingerprint := hex.EncodeToString(entity.PrimaryKey.Fingerprint)
+			if level, ok := trust[fingerprint]; !ok || level < trustedKeyring.Trust {
+				trust[fingerprint] = trustedKeyring.Trust
+			}
+		}
+
+		keyring = append(keyring, entities...)
+	}
+
+	keyringId, keyringIdErr := getKeyringId(keyring, trust)
+	if keyringIdErr != nil {
+		return nil, keyringIdErr
+	}
+
+	return &Verifier{keyring: keyring, keyringId: keyringId, trust: trust}, nil
+}
+
+/*
+Returns a verifier trusting all the keys contained in the files of the given directory (ex: a trust store of .asc files managed by configuration management).
+Both armored and binary keys are supported and symbolic links to key files are followed. Sub-directories and files that don't contain keys are skipped,
+so the number of keys that were loaded can be checked with the KeyCount method. Returns an error if the directory doesn't contain any key.
+*/
+func NewVerifierFromDir(dir string) (*Verifier, error) {
+	entries, readDirErr := os.ReadDir(dir)
+	if readDirErr != nil {
+		return nil, fmt.Errorf("Error reading trusted keys directory \"%s\": %w", dir, readDirErr)
+	}
+
+	keyring := openpgp.EntityList{}
+	for _, entry := range entries {
+		//Symbolic links are followed, as the files of mounted secrets and config maps in Kubernetes are links to their content
+		keyPath := path.Join(dir, entry.Name())
+		info, statErr := os.Stat(keyPath)
+		if statErr != nil {
+			return nil, fmt.Errorf("Error accessing trusted key file %s: %w", keyPath, statErr)
+		}
+
+		if info.IsDir() {
+			continue
+		}
+
+		content, readErr := os.ReadFile(keyPath)
+		if readErr != nil {
+			return nil, fmt.Errorf("Error reading trusted key file %s: %w", keyPath, readErr)
+		}
+
+		entities, parseErr := openpgp.ReadArmoredKeyRing(bytes.NewReader(content))
+		if parseErr != nil {
+			entities, parseErr = openpgp.ReadKeyRing(bytes.NewReader(content))
+		}
+		if parseErr != nil || len(entities) == 0 {
+			logger.Printf("Skipping file %s of trusted keys directory as it doesn't contain any key", keyPath)
+			continue
+		}
+
+		keyring = append(keyring, entities...)
+	}
+
+	if len(keyring) == 0 {
+		return nil, fmt.Errorf("Trusted keys directory \"%s\" doesn't contain any key", dir)
+	}
+
+	logger.Printf("Loaded %d trusted keys from directory \"%s\"", len(keyring), dir)
+	keyringId, keyringIdErr := getKeyringId(keyring, nil)
+	if keyringIdErr != nil {
+		return nil, keyringIdErr
+	}
+
+	return &Verifier{keyring: keyring, keyringId: keyringId}, nil
+}
+
+//The serialized keys include their revocations, subkeys and self-signatures, so that any change to a key (and not only to the set of keys) changes the id
+func getKeyringId(keyring openpgp.EntityList, trust map[string]TrustLevel) (string, error) {
+	hash := sha256.New()
+	for _, entity := range keyring {
+		serialized := bytes.Buffer{}
+		serializeErr := entity.Serialize(&serialized)
+		if serializeErr != nil {
+			return "", fmt.Errorf("Error serializing trusted key \"%s\": %w", hex.EncodeToString(entity.PrimaryKey.Fingerprint), serializeErr)
+		}
+		fmt.Fprintf(hash, "%d:", serialized.Len())
+		hash.Write(serialized.Bytes())
+	}
+
+	fingerprints := []string{}
+	for fingerprint := range trust {
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	sort.Strings(fingerprints)
+	for _, fingerprint := range fingerprints {
+		fmt.Fprintf(hash, "%s:%d,", fingerprint, trust[fingerprint])
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+type verificationResult struct {
+	entity *openpgp.Entity
+	err    error
+}
+
+type verificationCacheEntry struct {
+	key     string
+	result  verificationResult
+	expires time.Time
+}
+
+const (
+	DefaultVerificationCacheSize = 10000
+	DefaultVerificationCacheTTL  = time.Hour
+)
+
+/*
+Cache of commit signature verification results, keyed by commit hash, that can be attached to a Verifier to avoid repeating the cryptographic verification of the same commits.
+Results are also keyed by the trusted keys (including their revocations and subkeys), their trust levels and the options of the verifier,
+so a verifier with a different or updated keyring will never reuse results computed for another one.
+The cache is bounded: the least recently used results are evicted once it is full and results expire after a time to live,
+as the validity of a signature can change over time (ex: when the signing key expires). It is safe for concurrent use.
+*/
+type VerificationCache struct {
+	mutex      sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	entries    *list.List
+	results    map[string]*list.Element
+	now        func() time.Time
+}
+
+/*
+Returns an empty verification cache holding up to DefaultVerificationCacheSize results for DefaultVerificationCacheTTL
+*/
+func NewVerificationCache() *VerificationCache {
+	return NewVerificationCacheWithLimits(DefaultVerificationCacheSize, DefaultVerificationCacheTTL)
+}
+
+/*
+Returns an empty verification cache holding up to the given number of results, each for the given time to live
+*/
+func NewVerificationCacheWithLimits(maxEntries int, ttl time.Duration) *VerificationCache {
+	return &VerificationCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		entries:    list.New(),
+		results:    make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+/*
+Removes all the results from the cache
+*/
+func (c *VerificationCache) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries.Init()
+	c.results = make(map[string]*list.Element)
+}
+
+func (c *VerificationCache) get(key string) (verificationResult, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	elem, ok := c.results[key]
+	if !ok {
+		return verificationResult{}, false
+	}
+
+	entry := elem.Value.(*verificationCacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.entries.Remove(elem)
+		delete(c.results, key)
+		return verificationResult{}, false
+	}
+
+	c.entries.MoveToFront(elem)
+	return entry.result, true
+}
+
+func (c *VerificationCache) set(key string, result verificationResult) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.maxEntries <= 0 {
+		return
+	}
+
+	expires := c.now().Add(c.ttl)
+	if elem, ok := c.results[key]; ok {
+		entry := elem.Value.(*verificationCacheEntry)
+		entry.result = result
+		entry.expires = expires
+		c.entries.MoveToFront(elem)
+		return
+	}
+
+	for c.entries.Len() >= c.maxEntries {
+		oldest := c.entries.Back()
+		c.entries.Remove(oldest)
+		delete(c.results, oldest.Value.(*verificationCacheEntry).key)
+	}
+
+	c.results[key] = c.entries.PushFront(&verificationCacheEntry{key: key, result: result, expires: expires})
+}
+
+func getCommitSignedPayload(commit *object.Commit) ([]byte, error) {
+	encoded := &plumbing.MemoryObject{}
+	encErr := commit.EncodeWithoutSignature(encoded)
+	if encErr != nil {
+		return nil, fmt.Errorf("Error encoding commit \"%s\": %w", commit.Hash, encErr)
+	}
+
+	reader, readerErr := encoded.Reader()
+	if readerErr != nil {
+		return nil, fmt.Errorf("Error reading encoded commit \"%s\": %w", commit.Hash, readerErr)
+	}
+	defer reader.Close()
+
+	payload, readErr := ioutil.ReadAll(reader)
+	if readErr != nil {
+		return nil, fmt.Errorf("Error reading encoded commit \"%s\": %w", commit.Hash, readErr)
+	}
+
+	return payload, nil
+}
+
+/*
+Returns the number of keys trusted by the verifier
+*/
+func (v *Verifier) KeyCount() int {
+	return len(v.keyring)
+}
+
+func (v *Verifier) verifyCommit(commit *object.Commit) (*openpgp.Entity, error) {
+	if v.Cache == nil {
+		return v.checkCommitSignature(commit)
+	}
+
+	cacheKey := fmt.Sprintf("%s:%t:%t:%d:%s", v.keyringId, v.AtCommitTime, v.RequireAuthorIdentity, v.MinTrust, commit.Hash)
+	result, cached := v.Cache.get(cacheKey)
+	if !cached {
+		entity, err := v.checkCommitSignature(commit)
+		result = verificationResult{entity, err}
+		v.Cache.set(cacheKey, result)
+	}
+
+	return result.entity, result.err
+}
+
+func (v *Verifier) signatureConfig(commit *object.Commit) *packet.Config {
+	if !v.AtCommitTime {
+		return nil
+	}
+
+	commitTime := commit.Author.When
+	return &packet.Config{
+		Time: func() time.Time {
+			return commitTime
+		},
+	}
+}
+
+func (v *Verifier) checkCommitSignature(commit *object.Commit) (*openpgp.Entity, error) {
+	if commit.PGPSignature == "" {
+		return nil, fmt.Errorf("Error verifying commit \"%s\": %w", commit.Hash, ErrUnsignedCommit)
+	}
+
+	payload, payloadErr := getCommitSignedPayload(commit)
+	if payloadErr != nil {
+		return nil, payloadErr
+	}
+
+	entity, checkErr := openpgp.CheckArmoredDetachedSignature(v.keyring, bytes.NewReader(payload), strings.NewReader(commit.PGPSignature), v.signatureConfig(commit))
+	if checkErr != nil {
+		return nil, fmt.Errorf("Commit \"%s\" isn't signed with any of the trusted keys: %w", commit.Hash, checkErr)
+	}
+
+	signerErr := v.checkSigner(commit, entity)
+	if signerErr != nil {
+		return nil, signerErr
+	}
+
+	return entity, nil
+}
+
+//Applies the requirements of the verifier on the key that validated the signature of the commit, beyond the validity of the signature itself
+func (v *Verifier) checkSigner(commit *object.Commit, entity *openpgp.Entity) error {
+	if v.MinTrust > TrustUnknown {
+		level := v.trust[hex.EncodeToString(entity.PrimaryKey.Fingerprint)]
+		if level < v.MinTrust {
+			return fmt.Errorf("Commit \"%s\" is signed with a key of trust level \"%s\", below the required trust level \"%s\"", commit.Hash, level, v.MinTrust)
+		}
+	}
+
+	if v.RequireAuthorIdentity && !entityHasEmail(entity, commit.Author.Email) {
+		return fmt.Errorf("Author email \"%s\" of commit \"%s\" doesn't match any identity of its signing key", commit.Author.Email, commit.Hash)
+	}
+
+	return nil
+}
+
+func entityHasEmail(entity *openpgp.Entity, email string) bool {
+	for _, identity := range entity.Identities {
+		if identity.UserId != nil && strings.EqualFold(identity.UserId.Email, email) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+Verifies that the commit with the given hash was signed by one of the keys trusted by the verifier.
+Returns an error if it isn't.
+*/
+func (v *Verifier) VerifyCommit(repo *GitRepository, hash string) error {
+	commit, commitErr := repo.Repo.CommitObject(plumbing.NewHash(hash))
+	if commitErr != nil {
+		return fmt.Errorf("Error accessing commit \"%s\": %w", hash, commitErr)
+	}
+
+	entity, verifyErr := v.verifyCommit(commit)
+	if verifyErr != nil {
+		return verifyErr
+	}
+
+	for _, identity := range entity.Identities {
+		logger.Printf("Validated commit \"%s\" is signed by user \"%s\"", commit.Hash, (*identity).Name)
+	}
+
+	return nil
+}
+
+/*
+Returns all the keys trusted by the verifier that validate the signature of the commit with the given hash, instead of only the first one like VerifyCommit.
+Several keys can match if the same key (or keys sharing a subkey) appears more than once in the trusted keyrings, for example during a key rotation.
+Keys that validate the signature but don't satisfy the MinTrust and RequireAuthorIdentity requirements of the verifier are excluded, like in VerifyCommit.
+Returns an error if none of the trusted keys validate the signature and satisfy those requirements. Results are not cached.
+*/
+func (v *Verifier) GetMatchingKeys(repo *GitRepository, hash string) (openpgp.EntityList, error) {
+	commit, commitErr := repo.Repo.CommitObject(plumbing.NewHash(hash))
+	if commitErr != nil {
+		return nil, fmt.Errorf("Error accessing commit \"%s\": %w", hash, commitErr)
+	}
+
+	if commit.PGPSignature == "" {
+		return nil, fmt.Errorf("Error verifying commit \"%s\": %w", commit.Hash, ErrUnsignedCommit)
+	}
+
+	payload, payloadErr := getCommitSignedPayload(commit)
+	if payloadErr != nil {
+		return nil, payloadErr
+	}
+
+	matches := openpgp.EntityList{}
+	var signerErr error
+	var checkErr error = pgperrors.ErrUnknownIssuer
+	for _, entity := range v.keyring {
+		_, entityCheckErr := openpgp.CheckArmoredDetachedSignature(openpgp.EntityList{entity}, bytes.NewReader(payload), strings.NewReader(commit.PGPSignature), v.signatureConfig(commit))
+		if entityCheckErr != nil {
+			//Errors other than the key not being the issuer of the signature (ex: an expired key) are the most relevant to report
+			if !errors.Is(entityCheckErr, pgperrors.ErrUnknownIssuer) {
+				checkErr = entityCheckErr
+			}
+			continue
+		}
+
+		entitySignerErr := v.checkSigner(commit, entity)
+		if entitySignerErr != nil {
+			if signerErr == nil {
+				signerErr = entitySignerErr
+			}
+			continue
+		}
+
+		matches = append(matches, entity)
+	}
+
+	if len(matches) == 0 {
+		if signerErr != nil {
+			return nil, signerErr
+		}
+		return nil, fmt.Errorf("Commit \"%s\" isn't signed with any of the trusted keys: %w", commit.Hash, checkErr)
+	}
+
+	return matches, nil
+}
+
+/*
+Verifies that the top commit of a given git repository was signed by one of the keys trusted by the verifier.
+Returns an error if it isn't.
+*/
+func (v *Verifier) VerifyTopCommit(repo *GitRepository) error {
+	head, headErr := repo.Repo.Head()
+	if headErr != nil {
+		return fmt.Errorf("Error accessing repo head: %w", headErr)
+	}
+
+	return v.VerifyCommit(repo, head.Hash().String())
+}
+
+/*
+Verifies that the anchor commit is an ancestor of the top commit of the git repository along its first-parent chain
+and that every commit of that chain after the anchor was signed by one of the keys trusted by the verifier.
+The anchor itself is trusted as is. Returns an error identifying the first offending commit, starting from the anchor.
+*/
+func (v *Verifier) VerifyAncestryFrom(repo *GitRepository, anchorHash string) error {
+	anchor := plumbing.NewHash(anchorHash)
+
+	commit, commitErr := getHeadCommit(repo)
+	if commitErr != nil {
+		return commitErr
+	}
+
+	chain := []*object.Commit{}
+	for commit.Hash != anchor {
+		chain = append(chain, commit)
+
+		if commit.NumParents() == 0 {
+			return fmt.Errorf("Anchor commit \"%s\" is not a first-parent ancestor of the top commit", anchorHash)
+		}
+
+		parent, parentErr := commit.Parent(0)
+		if parentErr != nil {
+			return fmt.Errorf("Error accessing parent of commit \"%s\": %w", commit.Hash, parentErr)
+		}
+		commit = parent
+	}
+
+	for idx := len(chain) - 1; idx >= 0; idx-- {
+		_, verifyErr := v.verifyCommit(chain[idx])
+		if verifyErr != nil {
+			return fmt.Errorf("Ancestry verification from anchor commit \"%s\" failed: %w", anchorHash, verifyErr)
+		}
+	}
+
+	logger.Printf("Validated %d commits from anchor commit \"%s\" are signed with trusted keys", len(chain), anchorHash)
+	return nil
+}
+
+/*
+Verifies that the anchor commit is an ancestor of the top commit of the git repository along its first-parent chain
+and that every commit of that chain after the anchor was signed by one of the keys that are passed in the argument.
+Returns an error identifying the first offending commit, starting from the anchor.
+*/
+func VerifyAncestryFrom(repo *GitRepository, anchorHash string, armoredKeyrings []string) error {
+	verifier, verifierErr := NewVerifier(armoredKeyrings)
+	if verifierErr != nil {
+		return verifierErr
+	}
+
+	return verifier.VerifyAncestryFrom(repo, anchorHash)
+}
+
+/*
+Verifies that every commit of the given branch that isn't reachable from the base branch (ex: the commits of a feature branch that aren't on main yet)
+was signed by one of the keys trusted by the verifier. Commits are verified from the oldest to the newest and an error identifying the first offending one is returned.
+*/
+func (v *Verifier) VerifyBranchCommits(repo *GitRepository, branch string, baseBranch string) error {
+	hashes, hashesErr := commitHashesBetween(repo, baseBranch, branch)
+	if hashesErr != nil {
+		return hashesErr
+	}
+
+	commits := []*object.Commit{}
+	for _, hash := range hashes {
+		commit, commitErr := repo.Repo.CommitObject(hash)
+		if commitErr != nil {
+			return fmt.Errorf("Error accessing commit \"%s\": %w", hash, commitErr)
+		}
+		commits = append(commits, commit)
+	}
+
+	sort.SliceStable(commits, func(i, j int) bool {
+		return commits[i].Committer.When.Before(commits[j].Committer.When)
+	})
+
+	for _, commit := range commits {
+		_, verifyErr := v.verifyCommit(commit)
+		if verifyErr != nil {
+			return fmt.Errorf("Verification of branch \"%s\" against base branch \"%s\" failed: %w", branch, baseBranch, verifyErr)
+		}
+	}
+
+	logger.Printf("Validated %d commits of branch \"%s\" absent from base branch \"%s\" are signed with trusted keys", len(commits), branch, baseBranch)
+	return nil
+}
+
+/*
+Verifies that every commit of the given branch that isn't reachable from the base branch was signed by one of the keys that are passed in the argument.
+Returns an error identifying the first offending commit, from the oldest to the newest.
+*/
+func VerifyBranchCommits(repo *GitRepository, branch string, baseBranch string, armoredKeyrings []string) error {
+	verifier, verifierErr := NewVerifier(armoredKeyrings)
+	if verifierErr != nil {
+		return verifierErr
+	}
+
+	return verifier.VerifyBranchCommits(repo, branch, baseBranch)
+}
+
+//Signatures may only carry the fingerprint of their issuer key. The key id is the low 64 bits of a v4 fingerprint and the high 64 bits of a v5 one
+func getSignatureKeyId(signature *packet.Signature) (string, bool) {
+	if signature.IssuerKeyId != nil {
+		return fmt.Sprintf("%016X", *signature.IssuerKeyId), true
+	}
+
+	switch len(signature.IssuerFingerprint) {
+	case 20:
+		return fmt.Sprintf("%016X", binary.BigEndian.Uint64(signature.IssuerFingerprint[12:20])), true
+	case 32:
+		return fmt.Sprintf("%016X", binary.BigEndian.Uint64(signature.IssuerFingerprint[:8])), true
+	}
+
+	return "", false
+}
+
+/*
+Returns the id of the key that issued the signature of the commit with the given hash (in hexadecimal, like "gpg --list-keys --keyid-format long")
+and whether the commit is signed at all. The signature is only parsed, not verified, so this should not be used to make trust decisions.
+*/
+func GetCommitSigningKeyID(repo *GitRepository, hash string) (string, bool, error) {
+	commit, commitErr := repo.Repo.CommitObject(plumbing.NewHash(hash))
+	if commitErr != nil {
+		return "", false, fmt.Errorf("Error accessing commit \"%s\": %w", hash, commitErr)
+	}
+
+	if commit.PGPSignature == "" {
+		return "", false, nil
+	}
+
+	block, decErr := armor.Decode(strings.NewReader(commit.PGPSignature))
+	if decErr != nil {
+		return "", true, fmt.Errorf("Error decoding signature of commit \"%s\": %w", hash, decErr)
+	}
+
+	pkt, readErr := packet.NewReader(block.Body).Next()
+	if readErr != nil {
+		return "", true, fmt.Errorf("Error parsing signature of commit \"%s\": %w", hash, readErr)
+	}
+
+	signature, ok := pkt.(*packet.Signature)
+	if !ok {
+		return "", true, fmt.Errorf("Signature of commit \"%s\" is not a pgp signature packet", hash)
+	}
+
+	keyId, hasKeyId := getSignatureKeyId(signature)
+	if hasKeyId {
+		return keyId, true, nil
+	}
+
+	return "", true, fmt.Errorf("Signature of commit \"%s\" doesn't identify its issuer key", hash)
+}
+
+/*
+Returns the material needed to verify the signature of the commit with the given hash with external tooling:
+the canonical payload that was signed (the commit without its signature) and the armored signature.
+The armored signature is empty if the commit isn't signed.
+*/
+func GetCommitSignatureMaterial(repo *GitRepository, hash string) ([]byte, string, error) {
+	commit, commitErr := repo.Repo.CommitObject(plumbing.NewHash(hash))
+	if commitErr != nil {
+		return nil, "", fmt.Errorf("Error accessing commit \"%s\": %w", hash, commitErr)
+	}
+
+	payload, payloadErr := getCommitSignedPayload(commit)
+	if payloadErr != nil {
+		return nil, "", payloadErr
+	}
+
+	return payload, commit.PGPSignature, nil
+}
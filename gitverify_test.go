@@ -0,0 +1,267 @@
+package git
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+func newTestSignatureKey(t *testing.T, name string, email string) *CommitSignatureKey {
+	t.Helper()
+
+	entity, entityErr := openpgp.NewEntity(name, "", email, nil)
+	if entityErr != nil {
+		t.Fatal(entityErr)
+	}
+
+	return &CommitSignatureKey{Entity: entity}
+}
+
+func getTestArmoredPublicKey(t *testing.T, key *CommitSignatureKey) string {
+	t.Helper()
+
+	armored, armorErr := key.ArmoredPublicKey()
+	if armorErr != nil {
+		t.Fatal(armorErr)
+	}
+
+	return armored
+}
+
+func TestVerificationCacheNotReusedAfterKeyRevocation(t *testing.T) {
+	key := newTestSignatureKey(t, "Test Author", "author@example.com")
+	opts := testCommitOptions
+	opts.SignatureKey = key
+	repo, dir := newTestRepo(t, map[string]string{"a.txt": "a"})
+	paths := writeTestFiles(t, dir, map[string]string{"b.txt": "b"})
+	if _, err := CommitFiles(repo, paths, "Signed commit", opts); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := NewVerificationCache()
+	verifier, verifierErr := NewVerifier([]string{getTestArmoredPublicKey(t, key)})
+	if verifierErr != nil {
+		t.Fatal(verifierErr)
+	}
+	verifier.Cache = cache
+	if err := verifier.VerifyTopCommit(repo); err != nil {
+		t.Fatalf("expected commit to be verified, got: %s", err)
+	}
+
+	if err := key.Entity.RevokeKey(packet.KeyCompromised, "compromised", nil); err != nil {
+		t.Fatal(err)
+	}
+	revokedVerifier, revokedErr := NewVerifier([]string{getTestArmoredPublicKey(t, key)})
+	if revokedErr != nil {
+		t.Fatal(revokedErr)
+	}
+	revokedVerifier.Cache = cache
+	if revokedVerifier.keyringId == verifier.keyringId {
+		t.Fatal("expected the keyring id to change when a key is revoked")
+	}
+	if err := revokedVerifier.VerifyTopCommit(repo); err == nil {
+		t.Fatal("expected commit signed with a revoked key to be rejected")
+	}
+}
+
+func TestKeyringIdIncludesTrust(t *testing.T) {
+	key := newTestSignatureKey(t, "Test Author", "author@example.com")
+	armored := getTestArmoredPublicKey(t, key)
+
+	marginal, marginalErr := NewVerifierWithTrust([]TrustedKeyring{{ArmoredKeyring: armored, Trust: TrustMarginal}})
+	if marginalErr != nil {
+		t.Fatal(marginalErr)
+	}
+	full, fullErr := NewVerifierWithTrust([]TrustedKeyring{{ArmoredKeyring: armored, Trust: TrustFull}})
+	if fullErr != nil {
+		t.Fatal(fullErr)
+	}
+
+	if marginal.keyringId == full.keyringId {
+		t.Fatal("expected the keyring id to change with the trust levels of the keys")
+	}
+}
+
+func TestVerificationCacheLimits(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewVerificationCacheWithLimits(2, time.Minute)
+	cache.now = func() time.Time {
+		return now
+	}
+
+	cache.set("a", verificationResult{})
+	cache.set("b", verificationResult{})
+	if _, ok := cache.get("a"); !ok {
+		t.Fatal("expected result a to be cached")
+	}
+
+	cache.set("c", verificationResult{})
+	if _, ok := cache.get("b"); ok {
+		t.Fatal("expected least recently used result b to be evicted")
+	}
+	if _, ok := cache.get("a"); !ok {
+		t.Fatal("expected recently used result a to be kept")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := cache.get("c"); ok {
+		t.Fatal("expected result c to expire")
+	}
+	if len(cache.results) != 1 || cache.entries.Len() != 1 {
+		t.Fatalf("expected expired result to be removed, got %d results", len(cache.results))
+	}
+}
+
+func TestNewVerifierFromDirFollowsSymlinks(t *testing.T) {
+	key := newTestSignatureKey(t, "Test Author", "author@example.com")
+
+	//Mimics the layout of a mounted Kubernetes secret, where the visible files are links into a hidden data directory
+	dir := t.TempDir()
+	dataDir := filepath.Join(dir, "..data")
+	writeTestFiles(t, dataDir, map[string]string{"author.asc": getTestArmoredPublicKey(t, key)})
+	writeTestFiles(t, dir, map[string]string{"README": "Not a key"})
+	if err := os.Symlink(filepath.Join("..data", "author.asc"), filepath.Join(dir, "author.asc")); err != nil {
+		t.Fatal(err)
+	}
+
+	verifier, verifierErr := NewVerifierFromDir(dir)
+	if verifierErr != nil {
+		t.Fatal(verifierErr)
+	}
+	if verifier.KeyCount() != 1 {
+		t.Fatalf("expected 1 trusted key, got %d", verifier.KeyCount())
+	}
+}
+
+func TestGetMatchingKeysAppliesVerifierRequirements(t *testing.T) {
+	key := newTestSignatureKey(t, "Test Author", "author@example.com")
+	opts := CommitOptions{Name: "Impersonated", Email: "impersonated@example.com", SignatureKey: key}
+	repo, dir := newTestRepo(t, map[string]string{"a.txt": "a"})
+	paths := writeTestFiles(t, dir, map[string]string{"b.txt": "b"})
+	if _, err := CommitFiles(repo, paths, "Signed commit", opts); err != nil {
+		t.Fatal(err)
+	}
+	hash := getTestHeadCommit(t, repo).Hash.String()
+
+	verifier, verifierErr := NewVerifierWithTrust([]TrustedKeyring{{ArmoredKeyring: getTestArmoredPublicKey(t, key), Trust: TrustMarginal}})
+	if verifierErr != nil {
+		t.Fatal(verifierErr)
+	}
+
+	matches, matchErr := verifier.GetMatchingKeys(repo, hash)
+	if matchErr != nil || len(matches) != 1 {
+		t.Fatalf("Expected the signing key to match without requirements, got %d keys (%v)", len(matches), matchErr)
+	}
+
+	verifier.MinTrust = TrustFull
+	if _, err := verifier.GetMatchingKeys(repo, hash); err == nil || !strings.Contains(err.Error(), "trust level") {
+		t.Fatalf("Expected the marginally trusted key to be excluded, got: %v", err)
+	}
+	if err := verifier.VerifyCommit(repo, hash); err == nil {
+		t.Fatal("Expected VerifyCommit to reject the marginally trusted key as well")
+	}
+
+	verifier.MinTrust = TrustMarginal
+	verifier.RequireAuthorIdentity = true
+	if _, err := verifier.GetMatchingKeys(repo, hash); err == nil || !strings.Contains(err.Error(), "impersonated@example.com") {
+		t.Fatalf("Expected the key to be excluded for not matching the author email, got: %v", err)
+	}
+	if err := verifier.VerifyCommit(repo, hash); err == nil {
+		t.Fatal("Expected VerifyCommit to reject the author email as well")
+	}
+}
+
+func TestVerifyCommitWrapsSignatureError(t *testing.T) {
+	key := newTestSignatureKey(t, "Test Author", "author@example.com")
+	other := newTestSignatureKey(t, "Other Author", "other@example.com")
+	opts := testCommitOptions
+	opts.SignatureKey = key
+	repo, dir := newTestRepo(t, map[string]string{"a.txt": "a"})
+	paths := writeTestFiles(t, dir, map[string]string{"b.txt": "b"})
+	if _, err := CommitFiles(repo, paths, "Signed commit", opts); err != nil {
+		t.Fatal(err)
+	}
+	hash := getTestHeadCommit(t, repo).Hash.String()
+
+	verifier, verifierErr := NewVerifier([]string{getTestArmoredPublicKey(t, other)})
+	if verifierErr != nil {
+		t.Fatal(verifierErr)
+	}
+
+	if err := verifier.VerifyCommit(repo, hash); !errors.Is(err, pgperrors.ErrUnknownIssuer) {
+		t.Fatalf("Expected the signature error to be wrapped, got: %v", err)
+	}
+	if _, err := verifier.GetMatchingKeys(repo, hash); !errors.Is(err, pgperrors.ErrUnknownIssuer) {
+		t.Fatalf("Expected the signature error to be wrapped, got: %v", err)
+	}
+}
+
+func TestGetCommitSigningKeyIDFromIssuerFingerprint(t *testing.T) {
+	key := newTestSignatureKey(t, "Test Author", "author@example.com")
+	repo, _ := newTestRepo(t, map[string]string{"a.txt": "a"})
+	head := getTestHeadCommit(t, repo)
+	expected := fmt.Sprintf("%016X", key.Entity.PrimaryKey.KeyId)
+
+	//Signatures made by go-crypto only carry the issuer key id if it is set explicitly, so this one only has the issuer fingerprint
+	payload, payloadErr := getCommitSignedPayload(head)
+	if payloadErr != nil {
+		t.Fatal(payloadErr)
+	}
+	signature := &packet.Signature{
+		SigType:      packet.SigTypeBinary,
+		PubKeyAlgo:   key.Entity.PrivateKey.PubKeyAlgo,
+		Hash:         crypto.SHA256,
+		CreationTime: time.Now(),
+	}
+	digest := signature.Hash.New()
+	digest.Write(payload)
+	if err := signature.Sign(digest, key.Entity.PrivateKey, nil); err != nil {
+		t.Fatal(err)
+	}
+	if signature.IssuerKeyId != nil {
+		t.Fatal("Expected the signature not to carry the issuer key id")
+	}
+
+	var armored bytes.Buffer
+	armorWriter, armorErr := armor.Encode(&armored, "PGP SIGNATURE", nil)
+	if armorErr != nil {
+		t.Fatal(armorErr)
+	}
+	if err := signature.Serialize(armorWriter); err != nil {
+		t.Fatal(err)
+	}
+	armorWriter.Close()
+
+	signed := *head
+	signed.PGPSignature = armored.String()
+	hash := storeTestObject(t, repo, &signed)
+
+	keyId, isSigned, keyIdErr := GetCommitSigningKeyID(repo, hash.String())
+	if keyIdErr != nil || !isSigned || keyId != expected {
+		t.Fatalf("Expected key id %s, got %s (signed: %t, error: %v)", expected, keyId, isSigned, keyIdErr)
+	}
+
+	//The key id is the low 64 bits of v4 fingerprints and the high 64 bits of v5 ones
+	v4KeyId, v4Ok := getSignatureKeyId(&packet.Signature{IssuerFingerprint: key.Entity.PrimaryKey.Fingerprint})
+	if !v4Ok || v4KeyId != expected {
+		t.Fatalf("Expected key id %s from the v4 fingerprint, got %s", expected, v4KeyId)
+	}
+	v5Fingerprint := append([]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}, make([]byte, 24)...)
+	if v5KeyId, v5Ok := getSignatureKeyId(&packet.Signature{IssuerFingerprint: v5Fingerprint}); !v5Ok || v5KeyId != "0102030405060708" {
+		t.Fatalf("Expected key id 0102030405060708 from the v5 fingerprint, got %s", v5KeyId)
+	}
+	if _, ok := getSignatureKeyId(&packet.Signature{}); ok {
+		t.Fatal("Expected no key id for a signature without issuer")
+	}
+}
@@ -0,0 +1,446 @@
+package git
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func readWorktreeFile(w *gogit.Worktree, filePath string) ([]byte, error) {
+	fReader, openErr := w.Filesystem.Open(filePath)
+	if openErr != nil {
+		return nil, fmt.Errorf("Error opening file %s in worktree: %w", filePath, openErr)
+	}
+	defer fReader.Close()
+
+	content, readErr := ioutil.ReadAll(fReader)
+	if readErr != nil {
+		return nil, fmt.Errorf("Error reading file %s in worktree: %w", filePath, readErr)
+	}
+
+	return content, nil
+}
+
+func writeWorktreeFile(w *gogit.Worktree, filePath string, content []byte, mode os.FileMode) error {
+	mkdirErr := w.Filesystem.MkdirAll(path.Dir(filePath), 0755)
+	if mkdirErr != nil {
+		return fmt.Errorf("Error creating parent directory of file %s in worktree: %w", filePath, mkdirErr)
+	}
+
+	fWriter, openErr := w.Filesystem.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode)
+	if openErr != nil {
+		return fmt.Errorf("Error opening file %s in worktree: %w", filePath, openErr)
+	}
+	defer fWriter.Close()
+
+	_, writeErr := fWriter.Write(content)
+	if writeErr != nil {
+		return fmt.Errorf("Error writing file %s in worktree: %w", filePath, writeErr)
+	}
+
+	return nil
+}
+
+/*
+Returns the content of the file at the given path in the worktree of the git repository.
+The file is read through the worktree filesystem of the repository, so it works the same way for repositories on the filesystem and in memory.
+*/
+func ReadWorktreeFile(repo *GitRepository, filePath string) ([]byte, error) {
+	w, wErr := repo.Repo.Worktree()
+	if wErr != nil {
+		return nil, fmt.Errorf("Error accessing repo worktree: %w", wErr)
+	}
+
+	return readWorktreeFile(w, filePath)
+}
+
+/*
+Restores a single file of the worktree to its version at the given reference (branch, tag or commit hash) and stages it.
+The rest of the worktree is left untouched.
+*/
+func CheckoutFile(repo *GitRepository, ref string, filePath string) error {
+	commit, commitErr := resolveCommit(repo, ref)
+	if commitErr != nil {
+		return commitErr
+	}
+
+	file, fileErr := commit.File(filePath)
+	if fileErr != nil {
+		return fmt.Errorf("Error accessing file %s at reference \"%s\": %w", filePath, ref, fileErr)
+	}
+
+	fReader, readerErr := file.Reader()
+	if readerErr != nil {
+		return fmt.Errorf("Error reading file %s at reference \"%s\": %w", filePath, ref, readerErr)
+	}
+	defer fReader.Close()
+
+	content, readErr := ioutil.ReadAll(fReader)
+	if readErr != nil {
+		return fmt.Errorf("Error reading file %s at reference \"%s\": %w", filePath, ref, readErr)
+	}
+
+	mode, modeErr := file.Mode.ToOSFileMode()
+	if modeErr != nil {
+		return fmt.Errorf("Error converting mode of file %s: %w", filePath, modeErr)
+	}
+
+	w, wErr := repo.Repo.Worktree()
+	if wErr != nil {
+		return fmt.Errorf("Error accessing repo worktree: %w", wErr)
+	}
+
+	writeErr := writeWorktreeFile(w, filePath, content, mode)
+	if writeErr != nil {
+		return writeErr
+	}
+
+	_, addErr := w.Add(filePath)
+	if addErr != nil {
+		return fmt.Errorf("Error staging file %s: %w", filePath, addErr)
+	}
+
+	return nil
+}
+
+/*
+Returns the git blob hash of the current content of the given file in the worktree of the git repository.
+It can be compared with the result of HeadBlobHash to cheaply determine if the file differs from its committed version.
+*/
+func WorktreeBlobHash(repo *GitRepository, filePath string) (string, error) {
+	w, wErr := repo.Repo.Worktree()
+	if wErr != nil {
+		return "", fmt.Errorf("Error accessing repo worktree: %w", wErr)
+	}
+
+	content, readErr := readWorktreeFile(w, filePath)
+	if readErr != nil {
+		return "", readErr
+	}
+
+	return plumbing.ComputeHash(plumbing.BlobObject, content).String(), nil
+}
+
+/*
+Returns the git blob hash of the given file in the top commit of the git repository.
+*/
+func HeadBlobHash(repo *GitRepository, filePath string) (string, error) {
+	commit, commitErr := getHeadCommit(repo)
+	if commitErr != nil {
+		return "", commitErr
+	}
+
+	file, fileErr := commit.File(filePath)
+	if fileErr != nil {
+		return "", fmt.Errorf("Error accessing file %s in top commit: %w", filePath, fileErr)
+	}
+
+	return file.Hash.String(), nil
+}
+
+/*
+Deletes the tracked files of a directory from the worktree of the git repository and commits their deletion.
+Untracked and ignored files of the directory are kept, along with the sub-directories containing them. Other sub-directories left empty are removed.
+Works for both repositories on the filesystem and in memory.
+Returns an error for the root directory of the worktree (ex: "" or "."). If the directory doesn't contain any tracked files, nothing will be deleted and a commit will not be attempted.
+*/
+func DeleteDir(repo *GitRepository, dir string, msg string, opts CommitOptions) (bool, error) {
+	cleanDir := path.Clean(dir)
+	if cleanDir == "." || cleanDir == "/" {
+		return false, errors.New("Cannot delete the root directory of the worktree")
+	}
+
+	w, wErr := repo.Repo.Worktree()
+	if wErr != nil {
+		return false, fmt.Errorf("Error accessing repo worktree: %w", wErr)
+	}
+
+	idx, idxErr := repo.Repo.Storer.Index()
+	if idxErr != nil {
+		return false, fmt.Errorf("Error accessing repo index: %w", idxErr)
+	}
+
+	prefix := cleanDir + "/"
+	files := []string{}
+	for _, entry := range idx.Entries {
+		if strings.HasPrefix(entry.Name, prefix) {
+			files = append(files, entry.Name)
+		}
+	}
+
+	if len(files) == 0 {
+		logger.Printf("Will not commit as directory \"%s\" doesn't contain any tracked files.", dir)
+		return false, nil
+	}
+
+	dirs := map[string]bool{}
+	for _, file := range files {
+		removeErr := w.Filesystem.Remove(file)
+		if removeErr != nil && !os.IsNotExist(removeErr) {
+			return false, fmt.Errorf("Error deleting file %s: %w", file, removeErr)
+		}
+
+		for parent := path.Dir(file); parent != path.Dir(cleanDir); parent = path.Dir(parent) {
+			dirs[parent] = true
+		}
+	}
+
+	removeDirsErr := removeEmptyDirs(w, dirs)
+	if removeDirsErr != nil {
+		return false, removeDirsErr
+	}
+
+	return CommitFiles(repo, files, msg, opts)
+}
+
+//Removes the given directories that are empty, deepest first so that directories only containing empty directories are removed as well
+func removeEmptyDirs(w *gogit.Worktree, dirs map[string]bool) error {
+	sorted := []string{}
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return strings.Count(sorted[i], "/") > strings.Count(sorted[j], "/")
+	})
+
+	for _, dir := range sorted {
+		entries, readErr := w.Filesystem.ReadDir(dir)
+		if readErr != nil {
+			if os.IsNotExist(readErr) {
+				continue
+			}
+			return fmt.Errorf("Error reading directory %s: %w", dir, readErr)
+		}
+
+		if len(entries) > 0 {
+			continue
+		}
+
+		removeErr := w.Filesystem.Remove(dir)
+		if removeErr != nil && !os.IsNotExist(removeErr) {
+			return fmt.Errorf("Error deleting directory %s: %w", dir, removeErr)
+		}
+	}
+
+	return nil
+}
+
+//Returns an error if the path of a file of a tree could escape the checkout directory (ex: absolute paths or ".." components)
+func checkLocalTreePath(name string) error {
+	if name == "" || path.IsAbs(name) || strings.Contains(name, "\\") || path.Clean(name) != name {
+		return fmt.Errorf("Path \"%s\" of tree entry is not a local path", name)
+	}
+
+	for _, component := range strings.Split(name, "/") {
+		if component == ".." || component == "." || strings.EqualFold(component, ".git") {
+			return fmt.Errorf("Path \"%s\" of tree entry is not a local path", name)
+		}
+	}
+
+	return nil
+}
+
+//Returns an error if any parent directory of the file, under the checkout directory, is a symbolic link that the file would be written through
+func checkNoSymlinkParent(dir string, name string) error {
+	components := strings.Split(name, "/")
+	current := dir
+	for _, component := range components[:len(components)-1] {
+		current = path.Join(current, component)
+		info, statErr := os.Lstat(current)
+		if statErr != nil {
+			if os.IsNotExist(statErr) {
+				return nil
+			}
+			return fmt.Errorf("Error accessing directory %s: %w", current, statErr)
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("Cannot write file %s through symbolic link %s", name, current)
+		}
+	}
+
+	return nil
+}
+
+func checkEmptyDir(dir string) error {
+	entries, readErr := os.ReadDir(dir)
+	if readErr != nil {
+		if os.IsNotExist(readErr) {
+			return nil
+		}
+		return fmt.Errorf("Error reading directory \"%s\": %w", dir, readErr)
+	}
+
+	if len(entries) > 0 {
+		return fmt.Errorf("Directory \"%s\" must be empty to check out files in it", dir)
+	}
+
+	return nil
+}
+
+/*
+Writes the files of the given reference (branch, tag or commit hash) of the git repository in the given directory on the filesystem, creating it if needed.
+This allows several references to be read in parallel without contending for the worktree of the repository.
+Note that the directory is a snapshot: unlike a linked worktree ("git worktree add"), it is not tracked by the repository and changes made in it cannot be committed.
+As the reference may not be trusted (ex: the head of a pull request), the directory must be empty and files are never written outside of it:
+tree entries whose path is not local (ex: containing "..") are rejected and symbolic links are created as links, but files are never written through them.
+*/
+func CheckoutToDir(repo *GitRepository, ref string, dir string) error {
+	emptyErr := checkEmptyDir(dir)
+	if emptyErr != nil {
+		return emptyErr
+	}
+
+	commit, commitErr := resolveCommit(repo, ref)
+	if commitErr != nil {
+		return commitErr
+	}
+
+	files, filesErr := commit.Files()
+	if filesErr != nil {
+		return fmt.Errorf("Error accessing files at reference \"%s\": %w", ref, filesErr)
+	}
+	defer files.Close()
+
+	iterErr := files.ForEach(func(file *object.File) error {
+		localErr := checkLocalTreePath(file.Name)
+		if localErr != nil {
+			return localErr
+		}
+
+		symlinkParentErr := checkNoSymlinkParent(dir, file.Name)
+		if symlinkParentErr != nil {
+			return symlinkParentErr
+		}
+
+		filePath := path.Join(dir, file.Name)
+		mkdirErr := os.MkdirAll(path.Dir(filePath), 0755)
+		if mkdirErr != nil {
+			return fmt.Errorf("Error creating parent directory of file %s: %w", filePath, mkdirErr)
+		}
+
+		content, contentErr := file.Contents()
+		if contentErr != nil {
+			return fmt.Errorf("Error reading file %s at reference \"%s\": %w", file.Name, ref, contentErr)
+		}
+
+		if file.Mode == filemode.Symlink {
+			symlinkErr := os.Symlink(content, filePath)
+			if symlinkErr != nil {
+				return fmt.Errorf("Error creating symbolic link %s: %w", filePath, symlinkErr)
+			}
+			return nil
+		}
+
+		mode, modeErr := file.Mode.ToOSFileMode()
+		if modeErr != nil {
+			return fmt.Errorf("Error converting mode of file %s: %w", file.Name, modeErr)
+		}
+
+		//The file is created exclusively so that an existing symbolic link at its path is never followed
+		fWriter, createErr := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+		if createErr != nil {
+			return fmt.Errorf("Error creating file %s: %w", filePath, createErr)
+		}
+		defer fWriter.Close()
+
+		_, writeErr := fWriter.Write([]byte(content))
+		if writeErr != nil {
+			return fmt.Errorf("Error writing file %s: %w", filePath, writeErr)
+		}
+
+		return nil
+	})
+	if iterErr != nil {
+		return iterErr
+	}
+
+	logger.Printf("Checked out reference \"%s\" at commit %s in directory \"%s\"", ref, commit.Hash, dir)
+	return nil
+}
+
+/*
+Verifies the files of the worktree of the git repository against a manifest of expected checksums, where the keys are the paths of the files
+and the values are their hexadecimal sha256 checksums. Returns the paths of the files whose content doesn't match their checksum, sorted.
+Files of the manifest that are missing from the worktree are returned as well.
+*/
+func VerifyChecksums(repo *GitRepository, manifest map[string]string) ([]string, error) {
+	w, wErr := repo.Repo.Worktree()
+	if wErr != nil {
+		return nil, fmt.Errorf("Error accessing repo worktree: %w", wErr)
+	}
+
+	mismatches := []string{}
+	for filePath, checksum := range manifest {
+		_, statErr := w.Filesystem.Lstat(filePath)
+		if statErr != nil {
+			if !os.IsNotExist(statErr) {
+				return nil, fmt.Errorf("Error accessing file %s in worktree: %w", filePath, statErr)
+			}
+
+			mismatches = append(mismatches, filePath)
+			continue
+		}
+
+		content, readErr := readWorktreeFile(w, filePath)
+		if readErr != nil {
+			return nil, readErr
+		}
+
+		sum := sha256.Sum256(content)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), checksum) {
+			mismatches = append(mismatches, filePath)
+		}
+	}
+	sort.Strings(mismatches)
+
+	return mismatches, nil
+}
+
+var tempDir string
+
+/*
+Sets the base directory in which the sdk creates the temporary directories it needs (ex: CheckoutToTempDir).
+Passing the empty string restores the default, which is the temporary directory of the system (os.TempDir).
+*/
+func SetTempDir(dir string) {
+	tempDir = dir
+}
+
+func getTempDir() string {
+	if tempDir == "" {
+		return os.TempDir()
+	}
+
+	return tempDir
+}
+
+/*
+Same as CheckoutToDir, but writes the files in a new temporary directory created under the directory set with SetTempDir and returns its path.
+The caller is responsible for removing the directory once done with it.
+*/
+func CheckoutToTempDir(repo *GitRepository, ref string) (string, error) {
+	dir, mkdirErr := os.MkdirTemp(getTempDir(), "git-sdk-checkout-")
+	if mkdirErr != nil {
+		return "", fmt.Errorf("Error creating temporary directory in \"%s\": %w", getTempDir(), mkdirErr)
+	}
+
+	checkoutErr := CheckoutToDir(repo, ref, dir)
+	if checkoutErr != nil {
+		os.RemoveAll(dir)
+		return "", checkoutErr
+	}
+
+	return dir, nil
+}
@@ -0,0 +1,198 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func storeTestObject(t *testing.T, repo *GitRepository, obj interface {
+	Encode(plumbing.EncodedObject) error
+}) plumbing.Hash {
+	t.Helper()
+
+	encoded := repo.Repo.Storer.NewEncodedObject()
+	if err := obj.Encode(encoded); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := repo.Repo.Storer.SetEncodedObject(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hash
+}
+
+func storeTestBlob(t *testing.T, repo *GitRepository, content string) plumbing.Hash {
+	t.Helper()
+
+	encoded := repo.Repo.Storer.NewEncodedObject()
+	encoded.SetType(plumbing.BlobObject)
+	writer, writerErr := encoded.Writer()
+	if writerErr != nil {
+		t.Fatal(writerErr)
+	}
+	if _, err := writer.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+
+	hash, err := repo.Repo.Storer.SetEncodedObject(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hash
+}
+
+//Stores a commit of the given root tree entries, which are not validated like the trees built by go-git, and returns its hash
+func storeTestCommit(t *testing.T, repo *GitRepository, entries []object.TreeEntry) string {
+	t.Helper()
+
+	treeHash := storeTestObject(t, repo, &object.Tree{Entries: entries})
+	signature := object.Signature{Name: "Test Author", Email: "author@example.com", When: time.Now()}
+	return storeTestObject(t, repo, &object.Commit{
+		Author:    signature,
+		Committer: signature,
+		Message:   "Untrusted commit",
+		TreeHash:  treeHash,
+	}).String()
+}
+
+func TestCheckoutToDir(t *testing.T) {
+	repo, _ := newTestRepo(t, map[string]string{"a.txt": "a", "sub/b.txt": "b"})
+	linkHash := storeTestBlob(t, repo, "a.txt")
+	head := getTestHeadCommit(t, repo)
+	tree, treeErr := head.Tree()
+	if treeErr != nil {
+		t.Fatal(treeErr)
+	}
+	entries := append([]object.TreeEntry{}, tree.Entries...)
+	entries = append(entries, object.TreeEntry{Name: "link", Mode: filemode.Symlink, Hash: linkHash})
+	hash := storeTestCommit(t, repo, entries)
+
+	dir := filepath.Join(t.TempDir(), "checkout")
+	if err := CheckoutToDir(repo, hash, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	content, readErr := os.ReadFile(filepath.Join(dir, "sub/b.txt"))
+	if readErr != nil || string(content) != "b" {
+		t.Fatalf("expected sub/b.txt to be checked out, got %q (%v)", content, readErr)
+	}
+	target, linkErr := os.Readlink(filepath.Join(dir, "link"))
+	if linkErr != nil || target != "a.txt" {
+		t.Fatalf("expected link to be materialised as a symbolic link to a.txt, got %q (%v)", target, linkErr)
+	}
+}
+
+func TestCheckoutToDirRequiresEmptyDir(t *testing.T) {
+	repo, _ := newTestRepo(t, map[string]string{"a.txt": "a"})
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"existing.txt": "existing"})
+
+	err := CheckoutToDir(repo, "HEAD", dir)
+	if err == nil || !strings.Contains(err.Error(), "must be empty") {
+		t.Fatalf("expected error about non-empty directory, got: %v", err)
+	}
+}
+
+func TestCheckoutToDirRejectsEscapingEntries(t *testing.T) {
+	repo, _ := newTestRepo(t, map[string]string{"a.txt": "a"})
+	blobHash := storeTestBlob(t, repo, "evil")
+	outside := t.TempDir()
+	outsideHash := storeTestBlob(t, repo, outside)
+	nestedHash := storeTestObject(t, repo, &object.Tree{Entries: []object.TreeEntry{
+		{Name: "evil.txt", Mode: filemode.Regular, Hash: blobHash},
+	}})
+
+	cases := map[string][]object.TreeEntry{
+		"parent directory": {
+			{Name: "..", Mode: filemode.Dir, Hash: nestedHash},
+		},
+		"git directory": {
+			{Name: ".git", Mode: filemode.Dir, Hash: nestedHash},
+		},
+		"write through symbolic link": {
+			{Name: "link", Mode: filemode.Symlink, Hash: outsideHash},
+			{Name: "link", Mode: filemode.Dir, Hash: nestedHash},
+		},
+	}
+
+	for name, entries := range cases {
+		hash := storeTestCommit(t, repo, entries)
+		dir := t.TempDir()
+		if err := CheckoutToDir(repo, hash, dir); err == nil {
+			t.Errorf("%s: expected checkout to be rejected", name)
+		}
+
+		for _, escaped := range []string{filepath.Join(dir, "../evil.txt"), filepath.Join(outside, "evil.txt")} {
+			if _, err := os.Lstat(escaped); err == nil {
+				t.Errorf("%s: expected no file to be written at %s", name, escaped)
+			}
+		}
+	}
+}
+
+func TestDeleteDir(t *testing.T) {
+	repo, dir := newTestRepo(t, map[string]string{
+		"keep.txt":            "keep\n",
+		"old/a.txt":           "a\n",
+		"old/nested/b.txt":    "b\n",
+		"old/untracked/c.txt": "c\n",
+	})
+	writeTestFiles(t, dir, map[string]string{"old/untracked/local.txt": "local\n"})
+
+	for _, root := range []string{"", ".", "./", "/"} {
+		if _, err := DeleteDir(repo, root, "Delete root", testCommitOptions); err == nil {
+			t.Errorf("Expected an error deleting the root directory with %q", root)
+		}
+	}
+
+	committed, deleteErr := DeleteDir(repo, "old/", "Delete old", testCommitOptions)
+	if deleteErr != nil {
+		t.Fatal(deleteErr)
+	}
+	if !committed {
+		t.Fatal("Expected the deletion to be committed")
+	}
+
+	assertTestFiles(t, getTestTreeFiles(t, getTestHeadCommit(t, repo)), map[string]string{"keep.txt": "keep\n"})
+
+	if content, err := os.ReadFile(filepath.Join(dir, "old/untracked/local.txt")); err != nil || string(content) != "local\n" {
+		t.Fatalf("Expected untracked file to be kept, got %q (%v)", content, err)
+	}
+	for _, removed := range []string{"old/a.txt", "old/nested", "old/untracked/c.txt"} {
+		if _, err := os.Lstat(filepath.Join(dir, removed)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed, got: %v", removed, err)
+		}
+	}
+}
+
+func TestDeleteDirInMemory(t *testing.T) {
+	remote := newTestRemote(t, map[string]string{"keep.txt": "keep\n", "old/a.txt": "a\n", "old/nested/b.txt": "b\n"})
+	repo, store, cloneErr := MemCloneGitRepo(remote, "main", 0, noCredentials{})
+	if cloneErr != nil {
+		t.Fatal(cloneErr)
+	}
+	defer store.Clear()
+
+	committed, deleteErr := DeleteDir(repo, "old", "Delete old", testCommitOptions)
+	if deleteErr != nil {
+		t.Fatal(deleteErr)
+	}
+	if !committed {
+		t.Fatal("Expected the deletion to be committed")
+	}
+
+	assertTestFiles(t, getTestTreeFiles(t, getTestHeadCommit(t, repo)), map[string]string{"keep.txt": "keep\n"})
+	if _, err := (*store.Fs).Lstat("old"); !os.IsNotExist(err) {
+		t.Fatalf("Expected the emptied directory to be removed, got: %v", err)
+	}
+}
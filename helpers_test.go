@@ -0,0 +1,135 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	gogit "github.com/go-git/go-git/v5"
+	gogitconf "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+type noCredentials struct{}
+
+func (c noCredentials) AuthMethod() transport.AuthMethod {
+	return nil
+}
+
+var testCommitOptions = CommitOptions{Name: "Test Author", Email: "author@example.com"}
+
+func writeTestFiles(t testing.TB, dir string, files map[string]string) []string {
+	t.Helper()
+
+	paths := []string{}
+	for filePath, content := range files {
+		fullPath := filepath.Join(dir, filePath)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, filePath)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+func setOrigin(t *testing.T, repo *GitRepository, url string) {
+	t.Helper()
+
+	_, err := repo.Repo.CreateRemote(&gogitconf.RemoteConfig{Name: "origin", URLs: []string{url}})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func pushHook(repo *GitRepository) PushPreHook {
+	return func() (*GitRepository, error) {
+		return repo, nil
+	}
+}
+
+//Returns the path of a bare repository whose main branch has a single commit with the given files
+func newTestRemote(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	remoteDir := t.TempDir()
+	remote, initErr := gogit.PlainInit(remoteDir, true)
+	if initErr != nil {
+		t.Fatal(initErr)
+	}
+	headRef := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName("main"))
+	if err := remote.Storer.SetReference(headRef); err != nil {
+		t.Fatal(err)
+	}
+
+	seedDir := t.TempDir()
+	seed, seedErr := InitRepo(seedDir, "main")
+	if seedErr != nil {
+		t.Fatal(seedErr)
+	}
+
+	paths := writeTestFiles(t, seedDir, files)
+	if _, err := CommitFiles(seed, paths, "Initial commit", testCommitOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	setOrigin(t, seed, remoteDir)
+	if err := PushChanges(pushHook(seed), "main", noCredentials{}, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	return remoteDir
+}
+
+func getTestHeadCommit(t *testing.T, repo *GitRepository) *object.Commit {
+	t.Helper()
+
+	commit, err := getHeadCommit(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return commit
+}
+
+//Returns the content of the files of the tree of the given commit, keyed by path
+func getTestTreeFiles(t *testing.T, commit *object.Commit) map[string]string {
+	t.Helper()
+
+	tree, treeErr := commit.Tree()
+	if treeErr != nil {
+		t.Fatal(treeErr)
+	}
+
+	files := map[string]string{}
+	iterErr := tree.Files().ForEach(func(file *object.File) error {
+		content, err := file.Contents()
+		files[file.Name] = content
+		return err
+	})
+	if iterErr != nil {
+		t.Fatal(iterErr)
+	}
+
+	return files
+}
+
+func assertTestFiles(t *testing.T, got map[string]string, expected map[string]string) {
+	t.Helper()
+
+	if len(got) != len(expected) {
+		t.Fatalf("Expected files %v, got %v", expected, got)
+	}
+	for filePath, content := range expected {
+		if got[filePath] != content {
+			t.Fatalf("Expected file %s to contain %q, got %q", filePath, content, got[filePath])
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package testutils
+
+import (
+	"fmt"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+
+	git "github.com/Ferlab-Ste-Justine/git-sdk"
+)
+
+/*
+Generates a fresh unencrypted openpgp key pair in memory for the given identity, so that tests can sign and verify commits without checked-in private keys.
+The signing key can be passed in the commit options of CommitFiles and the returned armored public key can be passed to VerifyTopCommit.
+*/
+func GenerateTestSigningKey(name string, email string) (*git.CommitSignatureKey, string, error) {
+	entity, entityErr := openpgp.NewEntity(name, "", email, nil)
+	if entityErr != nil {
+		return nil, "", fmt.Errorf("Error generating test signing key: %w", entityErr)
+	}
+
+	key := &git.CommitSignatureKey{Entity: entity}
+	armored, armoredErr := key.ArmoredPublicKey()
+	if armoredErr != nil {
+		return nil, "", armoredErr
+	}
+
+	return key, armored, nil
+}